@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"spawnr/internal/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxCapabilityNamespaces bounds the namespaces checked by one request
+	maxCapabilityNamespaces = 20
+	// capabilitiesTimeout bounds the permission checks of one request
+	capabilitiesTimeout = 10 * time.Second
+)
+
+// CapabilitiesResponse maps each namespace to the actions spawnr may perform
+// in it
+type CapabilitiesResponse struct {
+	Namespaces map[string]map[string]k8s.Capability `json:"namespaces"`
+}
+
+// GetCapabilities checks which actions the cluster identity of spawnr may
+// perform in the namespaces given by the repeatable namespace query parameter
+func (h *Handlers) GetCapabilities(c *gin.Context) {
+	namespaces := c.QueryArray("namespace")
+	if len(namespaces) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "namespace is required"})
+		return
+	}
+	if len(namespaces) > maxCapabilityNamespaces {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d namespaces can be checked at once", maxCapabilityNamespaces)})
+		return
+	}
+
+	client, err := h.clientFor(c)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), capabilitiesTimeout)
+	defer cancel()
+
+	response := CapabilitiesResponse{Namespaces: make(map[string]map[string]k8s.Capability, len(namespaces))}
+	for _, namespace := range namespaces {
+		if _, ok := response.Namespaces[namespace]; ok {
+			continue
+		}
+		capabilities, err := client.Capabilities(ctx, namespace)
+		if err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		response.Namespaces[namespace] = capabilities
+	}
+
+	c.JSON(http.StatusOK, response)
+}
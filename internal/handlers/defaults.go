@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	batchv1 "k8s.io/api/batch/v1"
+)
+
+// JobDefaults are applied to every job whose create request leaves the
+// corresponding field unset. Nil values keep the cluster defaults.
+type JobDefaults struct {
+	BackoffLimit            *int32 `json:"backoffLimit,omitempty"`
+	ActiveDeadlineSeconds   *int64 `json:"activeDeadlineSeconds,omitempty"`
+	TTLSecondsAfterFinished *int32 `json:"ttlSecondsAfterFinished,omitempty"`
+}
+
+// JobDefaultsFromEnv reads the job defaults from the SPAWNR_JOB_BACKOFF_LIMIT,
+// SPAWNR_JOB_ACTIVE_DEADLINE_SECONDS and SPAWNR_JOB_TTL_SECONDS_AFTER_FINISHED
+// environment variables
+func JobDefaultsFromEnv() (JobDefaults, error) {
+	var defaults JobDefaults
+	var err error
+
+	if defaults.BackoffLimit, err = envInt32("SPAWNR_JOB_BACKOFF_LIMIT"); err != nil {
+		return defaults, err
+	}
+	if defaults.TTLSecondsAfterFinished, err = envInt32("SPAWNR_JOB_TTL_SECONDS_AFTER_FINISHED"); err != nil {
+		return defaults, err
+	}
+
+	if value := os.Getenv("SPAWNR_JOB_ACTIVE_DEADLINE_SECONDS"); value != "" {
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || seconds <= 0 {
+			return defaults, fmt.Errorf("SPAWNR_JOB_ACTIVE_DEADLINE_SECONDS must be a positive integer, got %q", value)
+		}
+		defaults.ActiveDeadlineSeconds = &seconds
+	}
+
+	return defaults, nil
+}
+
+// envInt32 parses an optional non-negative integer environment variable
+func envInt32(name string) (*int32, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || parsed < 0 {
+		return nil, fmt.Errorf("%s must be a non-negative integer, got %q", name, value)
+	}
+
+	result := int32(parsed)
+	return &result, nil
+}
+
+// GetJobDefaults returns the server-side job defaults so clients can show them
+func (h *Handlers) GetJobDefaults(c *gin.Context) {
+	c.JSON(http.StatusOK, h.jobDefaults)
+}
+
+// validateJobSpec checks the job spec controls of the overrides
+func (o *JobOverrides) validateJobSpec() error {
+	if o.BackoffLimit != nil && *o.BackoffLimit < 0 {
+		return fmt.Errorf("backoffLimit must not be negative")
+	}
+	if o.ActiveDeadlineSeconds != nil && *o.ActiveDeadlineSeconds <= 0 {
+		return fmt.Errorf("activeDeadlineSeconds must be positive")
+	}
+	if o.TTLSecondsAfterFinished != nil && *o.TTLSecondsAfterFinished < 0 {
+		return fmt.Errorf("ttlSecondsAfterFinished must not be negative")
+	}
+	if o.Completions != nil && *o.Completions < 0 {
+		return fmt.Errorf("completions must not be negative")
+	}
+	if o.Parallelism != nil && *o.Parallelism < 0 {
+		return fmt.Errorf("parallelism must not be negative")
+	}
+
+	switch o.CompletionMode {
+	case "", batchv1.NonIndexedCompletion:
+	case batchv1.IndexedCompletion:
+		if o.Completions == nil {
+			return fmt.Errorf("completions is required when completionMode is Indexed")
+		}
+	default:
+		return fmt.Errorf("completionMode must be %s or %s", batchv1.NonIndexedCompletion, batchv1.IndexedCompletion)
+	}
+
+	return nil
+}
+
+// applyJobDefaults sets the server defaults on the spec of a new job
+func (h *Handlers) applyJobDefaults(spec *batchv1.JobSpec) {
+	spec.BackoffLimit = h.jobDefaults.BackoffLimit
+	spec.ActiveDeadlineSeconds = h.jobDefaults.ActiveDeadlineSeconds
+	spec.TTLSecondsAfterFinished = h.jobDefaults.TTLSecondsAfterFinished
+}
+
+// applyJobSpec sets the job spec controls given in the overrides, keeping the
+// current values for the fields left unset
+func (o *JobOverrides) applyJobSpec(spec *batchv1.JobSpec) {
+	if o.BackoffLimit != nil {
+		spec.BackoffLimit = o.BackoffLimit
+	}
+	if o.ActiveDeadlineSeconds != nil {
+		spec.ActiveDeadlineSeconds = o.ActiveDeadlineSeconds
+	}
+	if o.TTLSecondsAfterFinished != nil {
+		spec.TTLSecondsAfterFinished = o.TTLSecondsAfterFinished
+	}
+	if o.Completions != nil {
+		spec.Completions = o.Completions
+	}
+	if o.Parallelism != nil {
+		spec.Parallelism = o.Parallelism
+	}
+	if o.CompletionMode != "" {
+		mode := o.CompletionMode
+		spec.CompletionMode = &mode
+	}
+}
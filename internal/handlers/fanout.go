@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"spawnr/internal/k8s"
+
+	"github.com/gin-gonic/gin"
+	batchv1 "k8s.io/api/batch/v1"
+)
+
+// AllClusters selects every registered cluster when listing jobs
+const AllClusters = "*"
+
+// clusterListTimeout bounds how long listing all clusters waits for one
+const clusterListTimeout = 10 * time.Second
+
+// ClusterJobs is the outcome of listing the jobs of one cluster
+type ClusterJobs struct {
+	Cluster string `json:"cluster"`
+	// Jobs is the number of jobs listed from the cluster before filtering
+	Jobs  int    `json:"jobs"`
+	Error string `json:"error,omitempty"`
+}
+
+// getJobsAcrossClusters lists the jobs of every registered cluster
+// concurrently. Clusters that fail or time out are reported in the response
+// instead of failing the whole list.
+func (h *Handlers) getJobsAcrossClusters(c *gin.Context, query *jobListQuery, raw bool) {
+	clusters, err := k8s.ListEKSClusters()
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	results := make([]ClusterJobs, len(clusters))
+	jobs := make([][]batchv1.Job, len(clusters))
+
+	var wg sync.WaitGroup
+	for i, cluster := range clusters {
+		name := cluster.OriginalName
+		if name == "" {
+			name = cluster.Name
+		}
+		results[i].Cluster = name
+
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			clusterJobs, err := h.listClusterJobs(name)
+			if err != nil {
+				fmt.Printf("[GetAllJobs] Failed to list jobs of cluster %s: %v\n", name, err)
+				results[i].Error = err.Error()
+				return
+			}
+			jobs[i] = clusterJobs
+			results[i].Jobs = len(clusterJobs)
+		}(i, name)
+	}
+	wg.Wait()
+
+	var entries []jobListEntry
+	failed := 0
+	for i, result := range results {
+		if result.Error != "" {
+			failed++
+			continue
+		}
+		entries = append(entries, newJobListEntries(jobs[i], result.Cluster)...)
+	}
+
+	if failed == len(results) {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to list jobs of every cluster", "clusters": results})
+		return
+	}
+
+	page, total, next := query.apply(entries)
+	writeJobList(c, page, total, next, results, raw)
+}
+
+// listClusterJobs lists the jobs of one cluster, giving up after
+// clusterListTimeout. A listing that times out keeps running in the
+// background and fills the cluster's job cache for the next request.
+func (h *Handlers) listClusterJobs(cluster string) ([]batchv1.Job, error) {
+	type result struct {
+		jobs []batchv1.Job
+		err  error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		client, err := h.clients.Get(cluster)
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		jobs, err := client.ListAllSpawnrJobs()
+		done <- result{jobs: jobs, err: err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.jobs, r.err
+	case <-time.After(clusterListTimeout):
+		return nil, fmt.Errorf("timed out after %s", clusterListTimeout)
+	}
+}
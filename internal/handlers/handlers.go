@@ -3,50 +3,245 @@ package handlers
 import (
 	"fmt"
 	"net/http"
+	"os"
 	"regexp"
+	"strconv"
 	"strings"
-	"sync"
+	"time"
 
 	"spawnr/internal/k8s"
 
+	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// ClusterHeader is the request header used to select the cluster a request
+// targets. The "cluster" query parameter can be used instead, e.g. for SSE.
+const ClusterHeader = "X-Spawnr-Cluster"
+
 type Handlers struct {
-	k8sClient *k8s.Client
-	clientMu  sync.RWMutex
+	clients     *k8s.ClientPool
+	jobDefaults JobDefaults
+	// trustUserHeaders takes the user from the headers of an authenticating
+	// proxy, see requestUser
+	trustUserHeaders bool
 }
 
-func New(k8sClient *k8s.Client) *Handlers {
+func New(clients *k8s.ClientPool, jobDefaults JobDefaults, trustUserHeaders bool) *Handlers {
 	return &Handlers{
-		k8sClient: k8sClient,
+		clients:          clients,
+		jobDefaults:      jobDefaults,
+		trustUserHeaders: trustUserHeaders,
+	}
+}
+
+// requestCluster returns the cluster selected by the request, or "" for the local cluster
+func requestCluster(c *gin.Context) string {
+	if cluster := c.GetHeader(ClusterHeader); cluster != "" {
+		return cluster
 	}
+	return c.Query("cluster")
+}
+
+// clientFor returns the Kubernetes client for the cluster selected by the request
+func (h *Handlers) clientFor(c *gin.Context) (*k8s.Client, error) {
+	cluster := requestCluster(c)
+	if cluster == AllClusters {
+		return nil, fmt.Errorf("cluster %q is only supported when listing jobs", AllClusters)
+	}
+	return h.clients.Get(cluster)
+}
+
+// errorStatus returns the HTTP status for a failed Kubernetes call, passing
+// on missing objects as 404 and RBAC denials as 403 instead of 500
+func errorStatus(err error) int {
+	switch {
+	case apierrors.IsNotFound(err):
+		return http.StatusNotFound
+	case apierrors.IsForbidden(err):
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
 }
 
 type CreateJobRequest struct {
 	Namespace  string `json:"namespace" binding:"required"`
 	Deployment string `json:"deployment" binding:"required"`
-	Command    string `json:"command" binding:"required"`
 	JobName    string `json:"jobName" binding:"required"`
+
+	JobOverrides
+
+	// SkipSanitize keeps probes, lifecycle hooks and selector labels of the
+	// deployment's pod template as they are
+	SkipSanitize bool `json:"skipSanitize"`
+
+	// Reason records why the job was created
+	Reason string `json:"reason"`
+	// RequestedBy records who requested the job when no authenticating proxy
+	// provides the user in a request header
+	RequestedBy string `json:"requestedBy"`
+
+	// NameMode controls how name collisions are avoided, defaults to NameModeExact
+	NameMode NameMode `json:"nameMode"`
+}
+
+// JobOverrides are the changes applied on top of a pod template, either the
+// deployment's when creating a job or an existing job's when rerunning it
+type JobOverrides struct {
+	// Command is run with /bin/sh -c in the target container
+	Command string `json:"command"`
+	// ExecCommand and ExecArgs are an exec-form alternative to Command for
+	// images without a shell
+	ExecCommand []string `json:"execCommand"`
+	ExecArgs    []string `json:"execArgs"`
+
+	// Container is the container to run the command in, defaults to the first one
+	Container string `json:"container"`
+	// DropOtherContainers removes every container except the target one,
+	// e.g. sidecars that would otherwise keep the job running
+	DropOtherContainers bool `json:"dropOtherContainers"`
+	// DropInitContainers removes the init containers of the deployment
+	DropInitContainers bool `json:"dropInitContainers"`
+
+	// Env adds or overrides environment variables on the target container
+	Env map[string]string `json:"env"`
+	// RemoveEnv removes environment variables from the target container
+	RemoveEnv []string `json:"removeEnv"`
+	// EnvFrom adds ConfigMap or Secret sources to the target container
+	EnvFrom []corev1.EnvFromSource `json:"envFrom"`
+	// Image replaces the image of the target container
+	Image string `json:"image"`
+
+	// Resources overrides requests and limits, keyed by container name
+	Resources map[string]corev1.ResourceRequirements `json:"resources"`
+	// NodeSelector is merged into the deployment's node selector
+	NodeSelector map[string]string `json:"nodeSelector"`
+	// Tolerations are added to the deployment's tolerations
+	Tolerations []corev1.Toleration `json:"tolerations"`
+	// Affinity replaces the deployment's affinity
+	Affinity *corev1.Affinity `json:"affinity"`
+	// PriorityClassName replaces the deployment's priority class
+	PriorityClassName string `json:"priorityClassName"`
+
+	// Job spec controls, unset values fall back to the server's JobDefaults
+	// and then to the cluster defaults when creating a job, and keep the
+	// existing values when rerunning one
+	BackoffLimit            *int32                 `json:"backoffLimit"`
+	ActiveDeadlineSeconds   *int64                 `json:"activeDeadlineSeconds"`
+	TTLSecondsAfterFinished *int32                 `json:"ttlSecondsAfterFinished"`
+	Completions             *int32                 `json:"completions"`
+	Parallelism             *int32                 `json:"parallelism"`
+	CompletionMode          batchv1.CompletionMode `json:"completionMode"`
+}
+
+// CreateJobResponse is the created job along with the changes made to the
+// deployment's pod template. Job is a JobSummary, or a JobView with ?raw=true.
+type CreateJobResponse struct {
+	Job     any          `json:"job"`
+	Changes []SpecChange `json:"changes"`
+}
+
+// JobView is a job along with the source details recorded by spawnr
+type JobView struct {
+	batchv1.Job
+	Source k8s.JobSource `json:"source"`
+	// Cluster is the cluster the job was listed from when listing several
+	Cluster string `json:"cluster,omitempty"`
+}
+
+// JobDetail is a job along with a diagnosis of why it is stuck or has failed
+type JobDetail struct {
+	JobSummary
+	Diagnosis *k8s.Diagnosis `json:"diagnosis,omitempty"`
+}
+
+// RawJobDetail is the raw form of JobDetail
+type RawJobDetail struct {
+	JobView
+	Diagnosis *k8s.Diagnosis `json:"diagnosis,omitempty"`
+}
+
+func newJobView(job *batchv1.Job) JobView {
+	return JobView{
+		Job:    *job,
+		Source: k8s.JobSourceOf(job),
+	}
+}
+
+// userHeaders are set by authenticating proxies such as oauth2-proxy
+var userHeaders = []string{
+	"X-Forwarded-User",
+	"X-Auth-Request-User",
+	"X-Forwarded-Email",
+	"X-Auth-Request-Email",
+}
+
+// TrustUserHeadersFromEnv reads SPAWNR_TRUST_USER_HEADERS, which must only be
+// enabled when every request reaches spawnr through an authenticating proxy
+// that sets or strips the user headers
+func TrustUserHeadersFromEnv() (bool, error) {
+	value := os.Getenv("SPAWNR_TRUST_USER_HEADERS")
+	if value == "" {
+		return false, nil
+	}
+
+	trust, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("SPAWNR_TRUST_USER_HEADERS must be true or false, got %q", value)
+	}
+	return trust, nil
+}
+
+// requestUser returns the user behind a request, preferring the identity set
+// by a trusted authenticating proxy over the one given in the request body.
+// Without a trusted proxy any client could send the headers.
+func (h *Handlers) requestUser(c *gin.Context, fallback string) string {
+	if !h.trustUserHeaders {
+		return fallback
+	}
+	for _, header := range userHeaders {
+		if user := c.GetHeader(header); user != "" {
+			return user
+		}
+	}
+	return fallback
 }
 
 func (h *Handlers) GetNamespaces(c *gin.Context) {
-	h.clientMu.RLock()
-	client := h.k8sClient
-	h.clientMu.RUnlock()
+	raw, err := wantsRaw(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	client, err := h.clientFor(c)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
 
 	fmt.Printf("[GetNamespaces] Handler using client with server: %s\n", client.GetServerURL())
 
 	namespaces, err := client.ListNamespaces()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, namespaces.Items)
+	if raw {
+		c.JSON(http.StatusOK, namespaces.Items)
+		return
+	}
+
+	summaries := make([]NamespaceSummary, 0, len(namespaces.Items))
+	for i := range namespaces.Items {
+		summaries = append(summaries, newNamespaceSummary(&namespaces.Items[i]))
+	}
+	c.JSON(http.StatusOK, summaries)
 }
 
 func (h *Handlers) GetDeployments(c *gin.Context) {
@@ -55,36 +250,65 @@ func (h *Handlers) GetDeployments(c *gin.Context) {
 		namespace = "default"
 	}
 
-	h.clientMu.RLock()
-	client := h.k8sClient
-	h.clientMu.RUnlock()
+	raw, err := wantsRaw(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	client, err := h.clientFor(c)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
 
 	fmt.Printf("[GetDeployments] Handler using client with server: %s for namespace: %s\n", client.GetServerURL(), namespace)
 
 	deployments, err := client.ListDeployments(namespace)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, deployments.Items)
+	if raw {
+		c.JSON(http.StatusOK, deployments.Items)
+		return
+	}
+
+	summaries := make([]DeploymentSummary, 0, len(deployments.Items))
+	for i := range deployments.Items {
+		summaries = append(summaries, newDeploymentSummary(&deployments.Items[i]))
+	}
+	c.JSON(http.StatusOK, summaries)
 }
 
 func (h *Handlers) GetDeployment(c *gin.Context) {
 	namespace := c.Param("namespace")
 	name := c.Param("name")
 
-	h.clientMu.RLock()
-	client := h.k8sClient
-	h.clientMu.RUnlock()
+	raw, err := wantsRaw(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	client, err := h.clientFor(c)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
 
 	deployment, err := client.GetDeployment(namespace, name)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, deployment)
+	if raw {
+		c.JSON(http.StatusOK, deployment)
+		return
+	}
+	c.JSON(http.StatusOK, newDeploymentSummary(deployment))
 }
 
 // sanitizeJobName converts a job name to a valid Kubernetes resource name
@@ -129,28 +353,41 @@ func (h *Handlers) CreateJob(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	raw, err := wantsRaw(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Sanitize the job name
 	sanitizedName := sanitizeJobName(req.JobName)
 
-	h.clientMu.RLock()
-	client := h.k8sClient
-	h.clientMu.RUnlock()
+	client, err := h.clientFor(c)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
 
 	// Get the deployment
 	deployment, err := client.GetDeployment(req.Namespace, req.Deployment)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
 	// Create job from deployment spec
 	job := &batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      sanitizedName,
 			Namespace: req.Namespace,
 			Labels: map[string]string{
-				"app.kubernetes.io/managed-by": "spawnr",
+				k8s.ManagedByLabel: k8s.ManagedByValue,
+			},
+			Annotations: map[string]string{
+				k8s.SourceDeploymentAnnotation: deployment.Namespace + "/" + deployment.Name,
 			},
 		},
 		Spec: batchv1.JobSpec{
@@ -161,129 +398,362 @@ func (h *Handlers) CreateJob(c *gin.Context) {
 		},
 	}
 
+	setJobName(&job.ObjectMeta, sanitizedName, req.NameMode, time.Now())
+
+	// Record the deployment revision the job was cloned from
+	if revision := deployment.Annotations["deployment.kubernetes.io/revision"]; revision != "" {
+		job.Annotations[k8s.SourceRevisionAnnotation] = revision
+	}
+	h.annotateSource(c, client, job, deployment, &req)
+
 	// Ensure pod template has the spawnr label
 	if job.Spec.Template.Labels == nil {
 		job.Spec.Template.Labels = make(map[string]string)
 	}
-	job.Spec.Template.Labels["app.kubernetes.io/managed-by"] = "spawnr"
+	job.Spec.Template.Labels[k8s.ManagedByLabel] = k8s.ManagedByValue
+
+	diff := specDiff{}
 
-	// Override the command in the first container
-	if len(job.Spec.Template.Spec.Containers) > 0 {
-		job.Spec.Template.Spec.Containers[0].Command = []string{"/bin/sh", "-c"}
-		job.Spec.Template.Spec.Containers[0].Args = []string{req.Command}
+	// Strip the fields that only make sense for the deployment's long running pods
+	if !req.SkipSanitize {
+		sanitizePodTemplate(&job.Spec.Template, deployment, &diff)
+	}
+
+	// Apply the overrides on top of the deployment's pod spec
+	h.applyJobDefaults(&job.Spec)
+	if err := req.JobOverrides.apply(job, &diff); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	// Set job to not restart
 	job.Spec.Template.Spec.RestartPolicy = corev1.RestartPolicyNever
 
 	createdJob, err := client.CreateJob(req.Namespace, job)
+	if apierrors.IsAlreadyExists(err) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": nameConflictMessage(&job.ObjectMeta, req.NameMode),
+		})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, createdJob)
+	if isLegacyAPI(c) {
+		c.JSON(http.StatusCreated, jobResponse(createdJob, raw))
+		return
+	}
+	c.JSON(http.StatusCreated, CreateJobResponse{
+		Job:     jobResponse(createdJob, raw),
+		Changes: diff,
+	})
 }
 
+// GetJob returns a job, along with its diagnosis with ?diagnose=true. The
+// diagnosis reads the logs of failed containers, so it is only made on demand.
 func (h *Handlers) GetJob(c *gin.Context) {
 	namespace := c.Param("namespace")
 	name := c.Param("name")
 
-	h.clientMu.RLock()
-	client := h.k8sClient
-	h.clientMu.RUnlock()
+	raw, err := wantsRaw(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	diagnose, err := queryBool(c, "diagnose")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	client, err := h.clientFor(c)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
 
 	job, err := client.GetJob(namespace, name)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, job)
+	// The job is still returned when it cannot be diagnosed
+	var diagnosis *k8s.Diagnosis
+	if diagnose {
+		if diagnosis, err = client.DiagnoseJob(job); err != nil {
+			fmt.Printf("[GetJob] Failed to diagnose job %s/%s: %v\n", namespace, name, err)
+		}
+	}
+
+	if raw {
+		c.JSON(http.StatusOK, RawJobDetail{JobView: newJobView(job), Diagnosis: diagnosis})
+		return
+	}
+	c.JSON(http.StatusOK, JobDetail{JobSummary: newJobSummary(job), Diagnosis: diagnosis})
+}
+
+// GetJobEvents returns the Kubernetes Events involving a job or its pods
+func (h *Handlers) GetJobEvents(c *gin.Context) {
+	namespace := c.Param("namespace")
+	name := c.Param("name")
+
+	client, err := h.clientFor(c)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	job, err := client.GetJob(namespace, name)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	events, err := client.ListJobEvents(job)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if events == nil {
+		events = []k8s.KubernetesEvent{}
+	}
+
+	c.JSON(http.StatusOK, events)
 }
 
 func (h *Handlers) DeleteJob(c *gin.Context) {
 	namespace := c.Param("namespace")
 	name := c.Param("name")
 
-	h.clientMu.RLock()
-	client := h.k8sClient
-	h.clientMu.RUnlock()
+	client, err := h.clientFor(c)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
 
-	err := client.DeleteJob(namespace, name)
+	err = client.DeleteJob(namespace, name)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Job deleted successfully"})
 }
 
+// GetJobLogs returns the logs of every pod and container of a job. The pod,
+// container, previous, tailLines, sinceSeconds, timestamps and limitBytes
+// query parameters narrow down the logs that are returned.
 func (h *Handlers) GetJobLogs(c *gin.Context) {
 	namespace := c.Param("namespace")
 	name := c.Param("name")
 
-	h.clientMu.RLock()
-	client := h.k8sClient
-	h.clientMu.RUnlock()
+	opts, err := logOptionsFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	logs, err := client.GetJobLogs(namespace, name)
+	client, err := h.clientFor(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"logs": logs})
+	containers, err := client.GetJobLogs(namespace, name, opts)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(containers) == 0 {
+		c.JSON(http.StatusOK, gin.H{"logs": "No pods found for this job", "containers": []k8s.ContainerLogs{}})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"logs":       combineLogs(containers),
+		"containers": containers,
+	})
 }
 
+// logOptionsFromQuery reads the log options from the query parameters
+func logOptionsFromQuery(c *gin.Context) (k8s.LogOptions, error) {
+	opts := k8s.LogOptions{
+		Pod:       c.Query("pod"),
+		Container: c.Query("container"),
+	}
+
+	var err error
+	if opts.Previous, err = queryBool(c, "previous"); err != nil {
+		return opts, err
+	}
+	if opts.Timestamps, err = queryBool(c, "timestamps"); err != nil {
+		return opts, err
+	}
+	if opts.TailLines, err = queryInt64(c, "tailLines"); err != nil {
+		return opts, err
+	}
+	if opts.SinceSeconds, err = queryInt64(c, "sinceSeconds"); err != nil {
+		return opts, err
+	}
+	if opts.LimitBytes, err = queryInt64(c, "limitBytes"); err != nil {
+		return opts, err
+	}
+
+	return opts, nil
+}
+
+// queryBool parses an optional boolean query parameter
+func queryBool(c *gin.Context, name string) (bool, error) {
+	value := c.Query(name)
+	if value == "" {
+		return false, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s must be true or false", name)
+	}
+	return parsed, nil
+}
+
+// queryInt64 parses an optional positive integer query parameter
+func queryInt64(c *gin.Context, name string) (*int64, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || parsed <= 0 {
+		return nil, fmt.Errorf("%s must be a positive integer", name)
+	}
+	return &parsed, nil
+}
+
+// combineLogs joins the logs of several containers, with a header naming the
+// pod and container before each one
+func combineLogs(containers []k8s.ContainerLogs) string {
+	if len(containers) == 1 && containers[0].Error == "" {
+		return containers[0].Logs
+	}
+
+	var b strings.Builder
+	for _, container := range containers {
+		kind := "container"
+		if container.Init {
+			kind = "init container"
+		}
+		fmt.Fprintf(&b, "==> pod %s, %s %s <==\n", container.Pod, kind, container.Container)
+		if container.Error != "" {
+			fmt.Fprintf(&b, "(%s)\n", container.Error)
+		}
+		b.WriteString(container.Logs)
+		if container.Logs != "" && !strings.HasSuffix(container.Logs, "\n") {
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// WatchJob streams typed k8s.JobWatchEvents for a job as Server-Sent Events
+// named after their type, until the job has completed or failed. Each event
+// has an ID a reconnecting client sends back in the Last-Event-ID header to
+// resume the watch where it left off.
 func (h *Handlers) WatchJob(c *gin.Context) {
 	namespace := c.Param("namespace")
 	name := c.Param("name")
 
-	// Set up Server-Sent Events
-	c.Header("Content-Type", "text/event-stream")
-	c.Header("Cache-Control", "no-cache")
-	c.Header("Connection", "keep-alive")
-	c.Header("Access-Control-Allow-Origin", "*")
+	client, err := h.clientFor(c)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
 
-	h.clientMu.RLock()
-	client := h.k8sClient
-	h.clientMu.RUnlock()
+	events, err := client.WatchJobEvents(c.Request.Context(), namespace, name, lastEventID(c))
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
 
-	events, err := client.WatchJobEvents(namespace, name)
+	streamSSE(c, events, func(event k8s.JobWatchEvent) sse.Event {
+		return sse.Event{Id: event.ID, Event: event.Type, Data: event}
+	})
+}
+
+// StreamJobLogs follows the logs of a job as Server-Sent Events, moving on to
+// the next pod when the job retries. Each event carries a k8s.LogStreamEvent
+// and is named after its type. The stream ends once the job has finished or
+// the client disconnects.
+func (h *Handlers) StreamJobLogs(c *gin.Context) {
+	namespace := c.Param("namespace")
+	name := c.Param("name")
+
+	opts, err := logOptionsFromQuery(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	for event := range events {
-		c.SSEvent("message", gin.H{"data": event})
-		c.Writer.Flush()
+	client, err := h.clientFor(c)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
 	}
+
+	events, err := client.StreamJobLogs(c.Request.Context(), namespace, name, opts)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	streamSSE(c, events, func(event k8s.LogStreamEvent) sse.Event {
+		return sse.Event{Event: event.Type, Data: event}
+	})
 }
 
+// GetClusters lists the clusters with their health. Set health=false to skip
+// the health probes and refresh=true to bypass the cached probe results.
 func (h *Handlers) GetClusters(c *gin.Context) {
 	clusters, err := k8s.ListEKSClusters()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
+	if c.Query("health") != "false" {
+		h.clients.CheckClusters(clusters, c.Query("refresh") == "true")
+	}
+
 	c.JSON(http.StatusOK, clusters)
 }
 
+// GetClusterHealth probes one cluster. Set refresh=true to bypass the cached
+// probe result.
+func (h *Handlers) GetClusterHealth(c *gin.Context) {
+	health := h.clients.Health(c.Param("name"), c.Query("refresh") == "true")
+	c.JSON(http.StatusOK, health)
+}
+
 func (h *Handlers) GetClusterInfo(c *gin.Context) {
 	clusterName := c.Param("name")
 
 	info, err := k8s.GetClusterInfo(clusterName)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, info)
 }
 
+// SwitchCluster validates that a client can be created for the cluster and
+// warms the client pool. It does not change the cluster used by other
+// requests, callers select the cluster on every request via ClusterHeader.
 func (h *Handlers) SwitchCluster(c *gin.Context) {
 	var request struct {
 		ClusterName string `json:"clusterName"`
@@ -294,48 +764,43 @@ func (h *Handlers) SwitchCluster(c *gin.Context) {
 		return
 	}
 
-	fmt.Printf("[SwitchCluster] Switching to cluster: %s\n", request.ClusterName)
+	fmt.Printf("[SwitchCluster] Preparing client for cluster: %s\n", request.ClusterName)
 
-	// Create a new client for the selected cluster using the user name
-	newClient, err := k8s.NewClientWithCluster(request.ClusterName)
+	client, err := h.clients.Get(request.ClusterName)
 	if err != nil {
 		fmt.Printf("[SwitchCluster] ERROR creating client for %s: %v\n", request.ClusterName, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	fmt.Printf("[SwitchCluster] Successfully created client for %s, server: %s\n", request.ClusterName, newClient.GetServerURL())
-
-	// Update the handler's client with write lock
-	h.clientMu.Lock()
-	oldServerURL := h.k8sClient.GetServerURL()
-	h.k8sClient = newClient
-	h.clientMu.Unlock()
-
-	fmt.Printf("[SwitchCluster] Client updated. Old server: %s, New server: %s\n", oldServerURL, newClient.GetServerURL())
+	fmt.Printf("[SwitchCluster] Client ready for %s, server: %s\n", request.ClusterName, client.GetServerURL())
 
 	c.JSON(http.StatusOK, gin.H{"message": "Switched to cluster " + request.ClusterName})
 }
 
-// AddCluster adds a new cluster
+// AddCluster adds a new cluster. The type field selects the kind of
+// credentials, see k8s.ClusterCredentials, and defaults to eks.
 func (h *Handlers) AddCluster(c *gin.Context) {
-	var request struct {
-		ClusterName          string `json:"clusterName" binding:"required"`
-		FriendlyName         string `json:"friendlyName" binding:"required"`
-		RoleArn              string `json:"roleArn" binding:"required"`
-		Endpoint             string `json:"endpoint" binding:"required"`
-		CertificateAuthority string `json:"certificateAuthority"` // Optional, will be fetched if not provided
-	}
+	var request k8s.ClusterCredentials
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
 
-	// Create the cluster secret (will fetch CA cert if not provided)
-	err := k8s.CreateClusterSecret(request.ClusterName, request.FriendlyName, request.RoleArn, request.Endpoint, request.CertificateAuthority)
+	if err := request.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Create the cluster secret (will fetch the CA cert of EKS clusters if not provided)
+	err := k8s.CreateClusterSecret(&request)
+	if apierrors.IsAlreadyExists(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("cluster %s already exists", request.ClusterName)})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -346,27 +811,91 @@ func (h *Handlers) AddCluster(c *gin.Context) {
 func (h *Handlers) DeleteCluster(c *gin.Context) {
 	clusterName := c.Param("name")
 
+	if strings.HasPrefix(clusterName, k8s.KubeContextPrefix) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kubeconfig contexts cannot be deleted, edit your kubeconfig instead"})
+		return
+	}
+
 	// Delete the cluster secret
 	err := k8s.DeleteClusterSecret(clusterName)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
+	// Drop the cached client so the removed cluster can no longer be used
+	h.clients.Evict(clusterName)
+
 	c.JSON(http.StatusOK, gin.H{"message": "Cluster deleted successfully"})
 }
 
-// GetAllJobs returns all jobs managed by spawnr across all namespaces
+// GetAllJobs lists the spawnr jobs as JobSummaries, or raw with ?raw=true, filtered, sorted and
+// paginated by the query parameters
 func (h *Handlers) GetAllJobs(c *gin.Context) {
-	h.clientMu.RLock()
-	client := h.k8sClient
-	h.clientMu.RUnlock()
+	query, err := parseJobListQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	raw, err := wantsRaw(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The bare array of the legacy jobs list has no room for the total, the
+	// continue token or the clusters, so a page would look like every job
+	if isLegacyAPI(c) && raw && (c.Query("limit") != "" || c.Query("continue") != "" || requestCluster(c) == AllClusters) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("limit, continue and cluster %q are only supported by /api/v1/jobs, or by /api/jobs with raw=false", AllClusters),
+		})
+		return
+	}
+
+	if requestCluster(c) == AllClusters {
+		h.getJobsAcrossClusters(c, query, raw)
+		return
+	}
+
+	client, err := h.clientFor(c)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
 
 	jobs, err := client.ListAllSpawnrJobs()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	page, total, next := query.apply(newJobListEntries(jobs, ""))
+	writeJobList(c, page, total, next, nil, raw)
+}
+
+// StreamJobs pushes every added, updated or deleted spawnr job of the
+// selected cluster as a Server-Sent Event named after the change, carrying the
+// JobSummary of the job. Clients load the jobs list once the stream is open
+// and apply the changes to it. The stream is closed when the client falls too
+// far behind, clients then reconnect and load the list again.
+func (h *Handlers) StreamJobs(c *gin.Context) {
+	client, err := h.clientFor(c)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, jobs)
+	changes, unsubscribe, err := client.WatchSpawnrJobs()
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	go func() {
+		<-c.Request.Context().Done()
+		unsubscribe()
+	}()
+
+	streamSSE(c, changes, func(change k8s.JobChange) sse.Event {
+		return sse.Event{Event: change.Type, Data: newJobSummary(change.Job)}
+	})
 }
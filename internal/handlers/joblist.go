@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"spawnr/internal/k8s"
+
+	"github.com/gin-gonic/gin"
+	batchv1 "k8s.io/api/batch/v1"
+)
+
+// maxJobListLimit caps the page size of GET /api/jobs
+const maxJobListLimit = 500
+
+// JobList is one page of the jobs list
+type JobList struct {
+	Items []JobSummary `json:"items"`
+	// Total is the number of jobs matching the filters across all pages
+	Total int `json:"total"`
+	// Continue is passed as the continue query parameter to get the next
+	// page, it is empty on the last page
+	Continue string `json:"continue,omitempty"`
+	// Clusters reports the outcome per cluster when listing all clusters
+	Clusters []ClusterJobs `json:"clusters,omitempty"`
+}
+
+// RawJobList is one page of the jobs list with raw jobs, see JobList
+type RawJobList struct {
+	Items    []JobView     `json:"items"`
+	Total    int           `json:"total"`
+	Continue string        `json:"continue,omitempty"`
+	Clusters []ClusterJobs `json:"clusters,omitempty"`
+}
+
+// jobListEntry is a job of the jobs list along with its summary
+type jobListEntry struct {
+	job     *batchv1.Job
+	summary JobSummary
+}
+
+// newJobListEntries summarizes jobs for the jobs list, tagging them with the
+// cluster they were listed from when listing several clusters
+func newJobListEntries(jobs []batchv1.Job, cluster string) []jobListEntry {
+	entries := make([]jobListEntry, 0, len(jobs))
+	for i := range jobs {
+		entry := jobListEntry{job: &jobs[i], summary: newJobSummary(&jobs[i])}
+		entry.summary.Cluster = cluster
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// jobListQuery holds the filters, sort order and page of GET /api/jobs
+type jobListQuery struct {
+	namespace     string
+	statuses      []string
+	deployment    string
+	createdBy     string
+	createdAfter  *time.Time
+	createdBefore *time.Time
+	search        string
+
+	sortField  string
+	descending bool
+
+	limit  int
+	offset int
+}
+
+// jobSortFields maps the sort query parameter to a comparison of two jobs
+var jobSortFields = map[string]func(a, b *JobSummary) int{
+	"created": func(a, b *JobSummary) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	},
+	"finished": func(a, b *JobSummary) int {
+		return finishedTime(a).Compare(finishedTime(b))
+	},
+	"name": func(a, b *JobSummary) int {
+		return strings.Compare(a.Name, b.Name)
+	},
+	"namespace": func(a, b *JobSummary) int {
+		return strings.Compare(a.Namespace, b.Namespace)
+	},
+	"status": func(a, b *JobSummary) int {
+		return strings.Compare(a.Status, b.Status)
+	},
+	"deployment": func(a, b *JobSummary) int {
+		return strings.Compare(a.Source.Deployment, b.Source.Deployment)
+	},
+}
+
+// finishedTime sorts running jobs after finished ones
+func finishedTime(job *JobSummary) time.Time {
+	if job.FinishedAt == nil {
+		return time.Unix(1<<62, 0)
+	}
+	return *job.FinishedAt
+}
+
+// jobStatusFilters maps the status query parameter to the job phases it matches
+var jobStatusFilters = map[string][]string{
+	"active":    {k8s.JobPhasePending, k8s.JobPhaseRunning},
+	"pending":   {k8s.JobPhasePending},
+	"running":   {k8s.JobPhaseRunning},
+	"succeeded": {k8s.JobPhaseSucceeded},
+	"failed":    {k8s.JobPhaseFailed},
+	"suspended": {k8s.JobPhaseSuspended},
+}
+
+// parseJobListQuery reads the jobs list query parameters
+func parseJobListQuery(c *gin.Context) (*jobListQuery, error) {
+	q := &jobListQuery{
+		namespace:  c.Query("namespace"),
+		deployment: c.Query("deployment"),
+		createdBy:  c.Query("createdBy"),
+		search:     strings.ToLower(c.Query("search")),
+		sortField:  "created",
+		descending: true,
+	}
+
+	if statuses := c.Query("status"); statuses != "" {
+		for _, status := range strings.Split(statuses, ",") {
+			phases, ok := jobStatusFilters[strings.ToLower(strings.TrimSpace(status))]
+			if !ok {
+				return nil, fmt.Errorf("invalid status %q, must be one of active, pending, running, succeeded, failed or suspended", status)
+			}
+			q.statuses = append(q.statuses, phases...)
+		}
+	}
+
+	var err error
+	if q.createdAfter, err = queryTime(c, "createdAfter"); err != nil {
+		return nil, err
+	}
+	if q.createdBefore, err = queryTime(c, "createdBefore"); err != nil {
+		return nil, err
+	}
+
+	if sortBy := c.Query("sort"); sortBy != "" {
+		q.descending = strings.HasPrefix(sortBy, "-")
+		q.sortField = strings.TrimPrefix(sortBy, "-")
+		if _, ok := jobSortFields[q.sortField]; !ok {
+			return nil, fmt.Errorf("invalid sort field %q, must be one of created, finished, name, namespace, status or deployment", q.sortField)
+		}
+	}
+
+	limit, err := queryInt64(c, "limit")
+	if err != nil {
+		return nil, err
+	}
+	if limit != nil {
+		if *limit > maxJobListLimit {
+			return nil, fmt.Errorf("limit must be at most %d", maxJobListLimit)
+		}
+		q.limit = int(*limit)
+	}
+
+	if token := c.Query("continue"); token != "" {
+		if q.offset, err = decodeContinueToken(token); err != nil {
+			return nil, err
+		}
+	}
+
+	return q, nil
+}
+
+// queryTime parses an optional RFC 3339 timestamp query parameter
+func queryTime(c *gin.Context, name string) (*time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC 3339 timestamp, e.g. 2024-05-01T00:00:00Z", name)
+	}
+	return &parsed, nil
+}
+
+// matches reports whether a job passes the filters of the query
+func (q *jobListQuery) matches(job *JobSummary) bool {
+	if q.namespace != "" && job.Namespace != q.namespace {
+		return false
+	}
+	if len(q.statuses) > 0 && !slices.Contains(q.statuses, job.Status) {
+		return false
+	}
+	if q.deployment != "" && !matchesDeployment(job.Source.Deployment, q.deployment) {
+		return false
+	}
+	if q.createdBy != "" && !strings.EqualFold(job.Source.RequestedBy, q.createdBy) {
+		return false
+	}
+	if q.createdAfter != nil && job.CreatedAt.Before(*q.createdAfter) {
+		return false
+	}
+	if q.createdBefore != nil && !job.CreatedAt.Before(*q.createdBefore) {
+		return false
+	}
+	if q.search != "" && !strings.Contains(strings.ToLower(job.Name), q.search) {
+		return false
+	}
+	return true
+}
+
+// matchesDeployment reports whether a source deployment, recorded as
+// namespace/name, is the deployment filtered by, given as name or
+// namespace/name
+func matchesDeployment(source, deployment string) bool {
+	if strings.Contains(deployment, "/") {
+		return source == deployment
+	}
+	if _, name, ok := strings.Cut(source, "/"); ok {
+		return name == deployment
+	}
+	return source == deployment
+}
+
+// apply filters, sorts and paginates the jobs, returning the page along
+// with the number of matching jobs and the continue token of the next page
+func (q *jobListQuery) apply(all []jobListEntry) (page []jobListEntry, total int, next string) {
+	entries := make([]jobListEntry, 0, len(all))
+	for _, entry := range all {
+		if q.matches(&entry.summary) {
+			entries = append(entries, entry)
+		}
+	}
+
+	compare := jobSortFields[q.sortField]
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := &entries[i].summary, &entries[j].summary
+		result := compare(a, b)
+		if result == 0 {
+			// Keep pages stable for equal sort keys
+			result = strings.Compare(a.Cluster+"/"+a.Namespace+"/"+a.Name, b.Cluster+"/"+b.Namespace+"/"+b.Name)
+		}
+		if q.descending {
+			return result > 0
+		}
+		return result < 0
+	})
+
+	start := min(q.offset, len(entries))
+	end := len(entries)
+	if q.limit > 0 && start+q.limit < end {
+		end = start + q.limit
+		next = encodeContinueToken(end)
+	}
+
+	return entries[start:end], len(entries), next
+}
+
+// writeJobList writes a page of the jobs list, as summaries or raw jobs
+func writeJobList(c *gin.Context, page []jobListEntry, total int, next string, clusters []ClusterJobs, raw bool) {
+	if isLegacyAPI(c) && raw {
+		items := make([]JobView, 0, len(page))
+		for _, entry := range page {
+			view := newJobView(entry.job)
+			view.Cluster = entry.summary.Cluster
+			items = append(items, view)
+		}
+		c.JSON(http.StatusOK, items)
+		return
+	}
+
+	if raw {
+		list := RawJobList{Items: make([]JobView, 0, len(page)), Total: total, Continue: next, Clusters: clusters}
+		for _, entry := range page {
+			view := newJobView(entry.job)
+			view.Cluster = entry.summary.Cluster
+			list.Items = append(list.Items, view)
+		}
+		c.JSON(http.StatusOK, list)
+		return
+	}
+
+	list := JobList{Items: make([]JobSummary, 0, len(page)), Total: total, Continue: next, Clusters: clusters}
+	for _, entry := range page {
+		list.Items = append(list.Items, entry.summary)
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// The continue token is the offset of the next page in the sorted list.
+// Jobs created or deleted between two requests shift the pages.
+func encodeContinueToken(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodeContinueToken(token string) (int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, fmt.Errorf("invalid continue token")
+	}
+	offset, err := strconv.Atoi(string(decoded))
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid continue token")
+	}
+	return offset, nil
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"spawnr/internal/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listedJobs returns jobs of the jobs list created one hour apart, in order
+func listedJobs() []jobListEntry {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	summaries := []JobSummary{
+		{Namespace: "default", Name: "migrate", Status: k8s.JobPhaseSucceeded, Source: k8s.JobSource{Deployment: "default/web", RequestedBy: "jane@example.com"}},
+		{Namespace: "default", Name: "backfill", Status: k8s.JobPhaseRunning, Source: k8s.JobSource{Deployment: "default/worker", RequestedBy: "john@example.com"}},
+		{Namespace: "batch", Name: "migrate-batch", Status: k8s.JobPhaseFailed, Source: k8s.JobSource{Deployment: "batch/web", RequestedBy: "Jane@example.com"}},
+		{Namespace: "batch", Name: "report", Status: k8s.JobPhasePending, Source: k8s.JobSource{Deployment: "batch/report"}},
+	}
+	entries := make([]jobListEntry, len(summaries))
+	for i, summary := range summaries {
+		summary.CreatedAt = start.Add(time.Duration(i) * time.Hour)
+		entries[i] = jobListEntry{summary: summary}
+	}
+	return entries
+}
+
+// jobListQueryOf parses the jobs list query of a raw query string
+func jobListQueryOf(t *testing.T, rawQuery string) (*jobListQuery, error) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/jobs?"+rawQuery, nil)
+	return parseJobListQuery(c)
+}
+
+// entryNames returns the names of the jobs of a page
+func entryNames(entries []jobListEntry) []string {
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.summary.Name)
+	}
+	return names
+}
+
+func TestJobListQueryApply(t *testing.T) {
+	tests := []struct {
+		query     string
+		want      []string
+		wantTotal int
+	}{
+		{"", []string{"report", "migrate-batch", "backfill", "migrate"}, 4},
+		{"sort=created", []string{"migrate", "backfill", "migrate-batch", "report"}, 4},
+		{"sort=name", []string{"backfill", "migrate", "migrate-batch", "report"}, 4},
+		{"sort=-namespace", []string{"migrate", "backfill", "report", "migrate-batch"}, 4},
+		{"namespace=batch", []string{"report", "migrate-batch"}, 2},
+		{"status=active", []string{"report", "backfill"}, 2},
+		{"status=failed,succeeded", []string{"migrate-batch", "migrate"}, 2},
+		{"deployment=web", []string{"migrate-batch", "migrate"}, 2},
+		{"deployment=batch/web", []string{"migrate-batch"}, 1},
+		{"createdBy=jane@example.com", []string{"migrate-batch", "migrate"}, 2},
+		{"search=MIGRATE", []string{"migrate-batch", "migrate"}, 2},
+		{"createdAfter=2024-05-01T01:00:00Z&createdBefore=2024-05-01T03:00:00Z", []string{"migrate-batch", "backfill"}, 2},
+		{"limit=3", []string{"report", "migrate-batch", "backfill"}, 4},
+		{"namespace=default&limit=1", []string{"backfill"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, err := jobListQueryOf(t, tt.query)
+			if err != nil {
+				t.Fatalf("parseJobListQuery failed: %v", err)
+			}
+			page, total, _ := query.apply(listedJobs())
+			if got := entryNames(page); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("page = %v, want %v", got, tt.want)
+			}
+			if total != tt.wantTotal {
+				t.Errorf("total = %d, want %d", total, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestJobListQueryPages(t *testing.T) {
+	var pages [][]string
+	rawQuery := "sort=created&limit=3"
+	for {
+		query, err := jobListQueryOf(t, rawQuery)
+		if err != nil {
+			t.Fatalf("parseJobListQuery(%q) failed: %v", rawQuery, err)
+		}
+		page, total, next := query.apply(listedJobs())
+		if total != 4 {
+			t.Errorf("total = %d, want 4", total)
+		}
+		pages = append(pages, entryNames(page))
+		if next == "" {
+			break
+		}
+		if len(pages) > 2 {
+			t.Fatal("the continue token does not end")
+		}
+		rawQuery = "sort=created&limit=3&continue=" + next
+	}
+
+	want := [][]string{{"migrate", "backfill", "migrate-batch"}, {"report"}}
+	if !reflect.DeepEqual(pages, want) {
+		t.Errorf("pages = %v, want %v", pages, want)
+	}
+}
+
+func TestContinueToken(t *testing.T) {
+	for _, offset := range []int{0, 1, 500, 123456} {
+		token := encodeContinueToken(offset)
+		if strings.ContainsAny(token, "=+/") {
+			t.Errorf("token %q is not URL safe", token)
+		}
+		got, err := decodeContinueToken(token)
+		if err != nil || got != offset {
+			t.Errorf("decodeContinueToken(encodeContinueToken(%d)) = %d, %v", offset, got, err)
+		}
+	}
+
+	for _, token := range []string{"not base64!", encodeContinueToken(-1), "YWJj"} {
+		if _, err := decodeContinueToken(token); err == nil {
+			t.Errorf("decodeContinueToken(%q) accepted an invalid token", token)
+		}
+	}
+
+	// An offset past the end, e.g. after jobs were deleted, is an empty page
+	query, err := jobListQueryOf(t, "continue="+encodeContinueToken(10))
+	if err != nil {
+		t.Fatalf("parseJobListQuery failed: %v", err)
+	}
+	if page, total, next := query.apply(listedJobs()); len(page) != 0 || total != 4 || next != "" {
+		t.Errorf("apply past the end = %v, %d, %q, want an empty last page", entryNames(page), total, next)
+	}
+}
+
+func TestParseJobListQueryErrors(t *testing.T) {
+	tests := map[string]string{
+		"status=done":            `invalid status "done"`,
+		"sort=size":              `invalid sort field "size"`,
+		"limit=501":              "limit must be at most 500",
+		"limit=0":                "limit",
+		"continue=%21":           "invalid continue token",
+		"createdAfter=yesterday": "createdAfter must be an RFC 3339 timestamp",
+	}
+	for rawQuery, want := range tests {
+		_, err := jobListQueryOf(t, rawQuery)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("parseJobListQuery(%q) = %v, want an error containing %q", rawQuery, err, want)
+		}
+	}
+}
+
+func TestMatchesDeployment(t *testing.T) {
+	tests := []struct {
+		source     string
+		deployment string
+		want       bool
+	}{
+		{"default/web", "web", true},
+		{"default/web", "default/web", true},
+		{"default/web", "batch/web", false},
+		{"default/web", "we", false},
+		{"default/web-api", "web", false},
+		{"web", "web", true},
+		{"", "web", false},
+	}
+	for _, tt := range tests {
+		if got := matchesDeployment(tt.source, tt.deployment); got != tt.want {
+			t.Errorf("matchesDeployment(%q, %q) = %v, want %v", tt.source, tt.deployment, got, tt.want)
+		}
+	}
+}
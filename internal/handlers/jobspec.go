@@ -0,0 +1,569 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"spawnr/internal/k8s"
+
+	"github.com/gin-gonic/gin"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// NameMode controls how the name of a new job is derived from the requested name
+type NameMode string
+
+const (
+	// NameModeExact uses the sanitized name as-is and fails if it is taken
+	NameModeExact NameMode = "exact"
+	// NameModeGenerate lets the API server append a random suffix via generateName
+	NameModeGenerate NameMode = "generate"
+	// NameModeTimestamp appends a -YYYYMMDD-HHMMSS suffix
+	NameModeTimestamp NameMode = "timestamp"
+)
+
+// timestampSuffixFormat is appended to job names in NameModeTimestamp
+const timestampSuffixFormat = "20060102-150405"
+
+// validate checks that the name mode is known
+func (m NameMode) validate() error {
+	switch m {
+	case "", NameModeExact, NameModeGenerate, NameModeTimestamp:
+		return nil
+	}
+	return fmt.Errorf("nameMode must be %s, %s or %s", NameModeExact, NameModeGenerate, NameModeTimestamp)
+}
+
+// setJobName sets the name or generateName of a job from a sanitized base name
+func setJobName(meta *metav1.ObjectMeta, base string, mode NameMode, now time.Time) {
+	switch mode {
+	case NameModeGenerate:
+		// The API server appends 5 random characters to generateName
+		meta.Name = ""
+		meta.GenerateName = truncateName(base, 63-5-1) + "-"
+	case NameModeTimestamp:
+		suffix := now.UTC().Format(timestampSuffixFormat)
+		meta.Name = truncateName(base, 63-len(suffix)-1) + "-" + suffix
+	default:
+		meta.Name = base
+	}
+}
+
+// nameConflictMessage explains how to avoid the collision of a job created
+// with setJobName in the given mode with an existing job
+func nameConflictMessage(meta *metav1.ObjectMeta, mode NameMode) string {
+	switch mode {
+	case NameModeGenerate:
+		return fmt.Sprintf("the name generated from %s is taken, retry the request", meta.GenerateName)
+	case NameModeTimestamp:
+		return fmt.Sprintf("job %s already exists, a job with the same name was created within the same second, use nameMode %q to create a new run at once", meta.Name, NameModeGenerate)
+	default:
+		return fmt.Sprintf("job %s already exists, use nameMode %q or %q to create a new run", meta.Name, NameModeGenerate, NameModeTimestamp)
+	}
+}
+
+// truncateName shortens a sanitized name without leaving a trailing hyphen
+func truncateName(name string, max int) string {
+	if len(name) > max {
+		name = name[:max]
+	}
+	return strings.TrimRight(name, "-")
+}
+
+// SpecChange describes one change made to the deployment's pod template
+// while turning it into a job
+type SpecChange struct {
+	Container string `json:"container,omitempty"`
+	Field     string `json:"field"`
+	From      string `json:"from,omitempty"`
+	To        string `json:"to,omitempty"`
+}
+
+// specDiff collects the changes applied to a pod template
+type specDiff []SpecChange
+
+func (d *specDiff) add(container, field, from, to string) {
+	*d = append(*d, SpecChange{
+		Container: container,
+		Field:     field,
+		From:      from,
+		To:        to,
+	})
+}
+
+// validate checks a create job request for missing or conflicting options
+func (r *CreateJobRequest) validate() error {
+	if !r.hasCommand() {
+		return fmt.Errorf("either command or execCommand/execArgs is required")
+	}
+	if err := r.JobOverrides.validate(); err != nil {
+		return err
+	}
+
+	return r.NameMode.validate()
+}
+
+// hasCommand reports whether the overrides replace the command
+func (o *JobOverrides) hasCommand() bool {
+	return o.Command != "" || len(o.ExecCommand) > 0 || len(o.ExecArgs) > 0
+}
+
+// validate checks the overrides for invalid or conflicting options
+func (o *JobOverrides) validate() error {
+	if o.Command != "" && (len(o.ExecCommand) > 0 || len(o.ExecArgs) > 0) {
+		return fmt.Errorf("command cannot be combined with execCommand/execArgs")
+	}
+
+	for name := range o.Env {
+		if errs := validation.IsEnvVarName(name); len(errs) > 0 {
+			return fmt.Errorf("invalid env name %q: %s", name, strings.Join(errs, ", "))
+		}
+	}
+	for _, name := range o.RemoveEnv {
+		if _, ok := o.Env[name]; ok {
+			return fmt.Errorf("env %q cannot be both set and removed", name)
+		}
+	}
+
+	for i, source := range o.EnvFrom {
+		switch {
+		case source.ConfigMapRef != nil && source.SecretRef != nil:
+			return fmt.Errorf("envFrom[%d] must reference either a configMap or a secret, not both", i)
+		case source.ConfigMapRef == nil && source.SecretRef == nil:
+			return fmt.Errorf("envFrom[%d] must reference a configMap or a secret", i)
+		case source.ConfigMapRef != nil && source.ConfigMapRef.Name == "":
+			return fmt.Errorf("envFrom[%d].configMapRef.name is required", i)
+		case source.SecretRef != nil && source.SecretRef.Name == "":
+			return fmt.Errorf("envFrom[%d].secretRef.name is required", i)
+		}
+		if source.Prefix != "" {
+			if errs := validation.IsEnvVarName(source.Prefix); len(errs) > 0 {
+				return fmt.Errorf("invalid envFrom[%d].prefix %q: %s", i, source.Prefix, strings.Join(errs, ", "))
+			}
+		}
+	}
+
+	if strings.ContainsAny(o.Image, " \t\n") {
+		return fmt.Errorf("invalid image %q", o.Image)
+	}
+
+	for key, value := range o.NodeSelector {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("invalid nodeSelector key %q: %s", key, strings.Join(errs, ", "))
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return fmt.Errorf("invalid nodeSelector value %q: %s", value, strings.Join(errs, ", "))
+		}
+	}
+	for i, toleration := range o.Tolerations {
+		if toleration.Operator == corev1.TolerationOpExists && toleration.Value != "" {
+			return fmt.Errorf("tolerations[%d].value must be empty when operator is Exists", i)
+		}
+	}
+	if o.PriorityClassName != "" {
+		if errs := validation.IsDNS1123Subdomain(o.PriorityClassName); len(errs) > 0 {
+			return fmt.Errorf("invalid priorityClassName %q: %s", o.PriorityClassName, strings.Join(errs, ", "))
+		}
+	}
+
+	return o.validateJobSpec()
+}
+
+// apply applies the overrides to the pod template and spec of a job
+func (o *JobOverrides) apply(job *batchv1.Job, diff *specDiff) error {
+	spec := &job.Spec.Template.Spec
+
+	if err := applyContainerOverrides(spec, o, diff); err != nil {
+		return err
+	}
+	if err := applyResourceOverrides(spec, o, diff); err != nil {
+		return err
+	}
+	applySchedulingOverrides(spec, o, diff)
+	o.applyJobSpec(&job.Spec)
+
+	return nil
+}
+
+// annotateSource records where a job came from in its labels and annotations
+func (h *Handlers) annotateSource(c *gin.Context, client *k8s.Client, job *batchv1.Job, deployment *appsv1.Deployment, req *CreateJobRequest) {
+	job.Labels[k8s.SourceDeploymentLabel] = k8s.LabelValue(deployment.Name)
+
+	if user := h.requestUser(c, req.RequestedBy); user != "" {
+		job.Annotations[k8s.RequestedByAnnotation] = user
+		job.Labels[k8s.CreatedByLabel] = k8s.LabelValue(user)
+	}
+	if req.Reason != "" {
+		job.Annotations[k8s.ReasonAnnotation] = req.Reason
+	}
+
+	cluster := requestCluster(c)
+	if cluster == "" {
+		cluster = k8s.LocalClusterName
+	}
+	job.Annotations[k8s.ClusterAnnotation] = cluster
+	job.Annotations[k8s.OriginalNameAnnotation] = req.JobName
+	job.Annotations[k8s.CommandAnnotation] = req.commandLine()
+
+	container := req.Container
+	if container == "" && len(deployment.Spec.Template.Spec.Containers) > 0 {
+		container = deployment.Spec.Template.Spec.Containers[0].Name
+	}
+	job.Annotations[k8s.ContainerAnnotation] = container
+
+	// The ReplicaSet and image digests are informational, so failing to read
+	// them must not prevent the job from being created
+	replicaSet, images, err := client.GetDeploymentSource(deployment)
+	if err != nil {
+		fmt.Printf("Warning: failed to resolve source of deployment %s/%s: %v\n", deployment.Namespace, deployment.Name, err)
+		return
+	}
+	job.Annotations[k8s.SourceReplicaSetAnnotation] = replicaSet
+	if len(images) > 0 {
+		if data, err := json.Marshal(images); err == nil {
+			job.Annotations[k8s.SourceImagesAnnotation] = string(data)
+		}
+	}
+}
+
+// commandLine returns the command requested for the job as a single line
+func (o *JobOverrides) commandLine() string {
+	if o.Command != "" {
+		return o.Command
+	}
+	return strings.Join(append(append([]string{}, o.ExecCommand...), o.ExecArgs...), " ")
+}
+
+// sanitizePodTemplate removes the parts of a deployment's pod template that
+// only make sense for long running pods: probes and lifecycle hooks are
+// dropped and the selector labels are replaced so the job pod is not picked
+// up by the deployment's Services and PodDisruptionBudgets
+func sanitizePodTemplate(template *corev1.PodTemplateSpec, deployment *appsv1.Deployment, diff *specDiff) {
+	sanitizeContainers(template.Spec.InitContainers, diff)
+	sanitizeContainers(template.Spec.Containers, diff)
+
+	selectorKeys := map[string]bool{
+		appsv1.DefaultDeploymentUniqueLabelKey: true,
+	}
+	if selector := deployment.Spec.Selector; selector != nil {
+		for key := range selector.MatchLabels {
+			selectorKeys[key] = true
+		}
+		for _, expression := range selector.MatchExpressions {
+			selectorKeys[expression.Key] = true
+		}
+	}
+
+	keys := make([]string, 0, len(selectorKeys))
+	for key := range selectorKeys {
+		if _, ok := template.Labels[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		diff.add("", "labels."+key, template.Labels[key], "")
+		delete(template.Labels, key)
+	}
+
+	if template.Labels == nil {
+		template.Labels = map[string]string{}
+	}
+	template.Labels[k8s.DeploymentLabel] = k8s.LabelValue(deployment.Name)
+}
+
+// sanitizeContainers removes probes and lifecycle hooks from containers
+func sanitizeContainers(containers []corev1.Container, diff *specDiff) {
+	for i := range containers {
+		container := &containers[i]
+		if container.LivenessProbe != nil {
+			diff.add(container.Name, "livenessProbe", "set", "")
+			container.LivenessProbe = nil
+		}
+		if container.ReadinessProbe != nil {
+			diff.add(container.Name, "readinessProbe", "set", "")
+			container.ReadinessProbe = nil
+		}
+		if container.StartupProbe != nil {
+			diff.add(container.Name, "startupProbe", "set", "")
+			container.StartupProbe = nil
+		}
+		if container.Lifecycle != nil {
+			diff.add(container.Name, "lifecycle", "set", "")
+			container.Lifecycle = nil
+		}
+	}
+}
+
+// containerIndex returns the index of the named container, or of the first
+// container when no name is given
+func containerIndex(spec *corev1.PodSpec, name string) (int, error) {
+	if len(spec.Containers) == 0 {
+		return -1, fmt.Errorf("deployment has no containers")
+	}
+	if name == "" {
+		return 0, nil
+	}
+
+	for i, container := range spec.Containers {
+		if container.Name == name {
+			return i, nil
+		}
+	}
+
+	return -1, fmt.Errorf("container %q not found in deployment", name)
+}
+
+// applyContainerOverrides applies the command, image and environment
+// overrides to the target container and drops the other containers and init
+// containers when requested
+func applyContainerOverrides(spec *corev1.PodSpec, o *JobOverrides, diff *specDiff) error {
+	idx, err := containerIndex(spec, o.Container)
+	if err != nil {
+		return err
+	}
+
+	target := &spec.Containers[idx]
+	oldCommand := commandLine(target)
+	if o.Command != "" {
+		// Shell form runs the command through /bin/sh
+		target.Command = []string{"/bin/sh", "-c"}
+		target.Args = []string{o.Command}
+	} else if o.hasCommand() {
+		// Exec form replaces the command when given and always replaces the args,
+		// so execArgs alone keeps the container's command with new arguments
+		if len(o.ExecCommand) > 0 {
+			target.Command = o.ExecCommand
+		}
+		target.Args = o.ExecArgs
+	}
+	if newCommand := commandLine(target); newCommand != oldCommand {
+		diff.add(target.Name, "command", oldCommand, newCommand)
+	}
+
+	if o.Image != "" && o.Image != target.Image {
+		diff.add(target.Name, "image", target.Image, o.Image)
+		target.Image = o.Image
+	}
+
+	applyEnvOverrides(target, o, diff)
+
+	if o.DropOtherContainers {
+		for _, container := range spec.Containers {
+			if container.Name != target.Name {
+				diff.add(container.Name, "container", "present", "removed")
+			}
+		}
+		spec.Containers = []corev1.Container{*target}
+	}
+	if o.DropInitContainers {
+		for _, container := range spec.InitContainers {
+			diff.add(container.Name, "initContainer", "present", "removed")
+		}
+		spec.InitContainers = nil
+	}
+
+	return nil
+}
+
+// applyResourceOverrides merges the requested resource requests and limits
+// into the containers and init containers they name
+func applyResourceOverrides(spec *corev1.PodSpec, o *JobOverrides, diff *specDiff) error {
+	// Apply in a stable order so the diff is deterministic
+	names := make([]string, 0, len(o.Resources))
+	for name := range o.Resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		container := findContainer(spec, name)
+		if container == nil {
+			return fmt.Errorf("resources: container %q not found in job", name)
+		}
+
+		override := o.Resources[name]
+		container.Resources.Requests = mergeResources(container.Resources.Requests, override.Requests, name, "resources.requests.", diff)
+		container.Resources.Limits = mergeResources(container.Resources.Limits, override.Limits, name, "resources.limits.", diff)
+
+		// Catch conflicts between inherited and overridden values early, the
+		// API server would only report them as an opaque validation error
+		for resourceName, request := range container.Resources.Requests {
+			if limit, ok := container.Resources.Limits[resourceName]; ok && request.Cmp(limit) > 0 {
+				return fmt.Errorf("resources: container %q requests %s %s which exceeds its limit %s",
+					name, request.String(), resourceName, limit.String())
+			}
+		}
+	}
+
+	return nil
+}
+
+// mergeResources overrides individual quantities of a resource list
+func mergeResources(current, override corev1.ResourceList, container, field string, diff *specDiff) corev1.ResourceList {
+	if len(override) == 0 {
+		return current
+	}
+	if current == nil {
+		current = corev1.ResourceList{}
+	}
+
+	names := make([]string, 0, len(override))
+	for name := range override {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		resourceName := corev1.ResourceName(name)
+		from := ""
+		if quantity, ok := current[resourceName]; ok {
+			from = quantity.String()
+		}
+		to := override[resourceName]
+		diff.add(container, field+name, from, to.String())
+		current[resourceName] = to
+	}
+
+	return current
+}
+
+// applySchedulingOverrides merges the node selector, appends the tolerations
+// and replaces the affinity and priority class of the pod
+func applySchedulingOverrides(spec *corev1.PodSpec, o *JobOverrides, diff *specDiff) {
+	keys := make([]string, 0, len(o.NodeSelector))
+	for key := range o.NodeSelector {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if spec.NodeSelector == nil {
+			spec.NodeSelector = map[string]string{}
+		}
+		diff.add("", "nodeSelector."+key, spec.NodeSelector[key], o.NodeSelector[key])
+		spec.NodeSelector[key] = o.NodeSelector[key]
+	}
+
+	for _, toleration := range o.Tolerations {
+		diff.add("", "tolerations", "", tolerationString(toleration))
+		spec.Tolerations = append(spec.Tolerations, toleration)
+	}
+
+	if o.Affinity != nil {
+		from := ""
+		if spec.Affinity != nil {
+			from = "set"
+		}
+		diff.add("", "affinity", from, "replaced")
+		spec.Affinity = o.Affinity
+	}
+
+	if o.PriorityClassName != "" && o.PriorityClassName != spec.PriorityClassName {
+		diff.add("", "priorityClassName", spec.PriorityClassName, o.PriorityClassName)
+		spec.PriorityClassName = o.PriorityClassName
+		// The priority value is resolved from the class by admission
+		spec.Priority = nil
+	}
+}
+
+// findContainer returns the named container or init container
+func findContainer(spec *corev1.PodSpec, name string) *corev1.Container {
+	for i := range spec.Containers {
+		if spec.Containers[i].Name == name {
+			return &spec.Containers[i]
+		}
+	}
+	for i := range spec.InitContainers {
+		if spec.InitContainers[i].Name == name {
+			return &spec.InitContainers[i]
+		}
+	}
+	return nil
+}
+
+// tolerationString describes a toleration for the diff
+func tolerationString(toleration corev1.Toleration) string {
+	s := toleration.Key
+	if toleration.Operator == corev1.TolerationOpExists {
+		s += " exists"
+	} else if toleration.Value != "" {
+		s += "=" + toleration.Value
+	}
+	if toleration.Effect != "" {
+		s += ":" + string(toleration.Effect)
+	}
+	return s
+}
+
+// applyEnvOverrides removes, overrides and adds environment variables on a
+// container, keeping the order of the variables that remain
+func applyEnvOverrides(container *corev1.Container, o *JobOverrides, diff *specDiff) {
+	removed := make(map[string]bool, len(o.RemoveEnv))
+	for _, name := range o.RemoveEnv {
+		removed[name] = true
+	}
+
+	env := make([]corev1.EnvVar, 0, len(container.Env)+len(o.Env))
+	seen := make(map[string]bool, len(container.Env))
+	for _, envVar := range container.Env {
+		if removed[envVar.Name] {
+			diff.add(container.Name, "env."+envVar.Name, envValue(envVar), "")
+			continue
+		}
+		if value, ok := o.Env[envVar.Name]; ok {
+			if envVar.ValueFrom != nil || envVar.Value != value {
+				diff.add(container.Name, "env."+envVar.Name, envValue(envVar), value)
+			}
+			envVar = corev1.EnvVar{Name: envVar.Name, Value: value}
+		}
+		seen[envVar.Name] = true
+		env = append(env, envVar)
+	}
+
+	// Append new variables in a stable order
+	names := make([]string, 0, len(o.Env))
+	for name := range o.Env {
+		if !seen[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		diff.add(container.Name, "env."+name, "", o.Env[name])
+		env = append(env, corev1.EnvVar{Name: name, Value: o.Env[name]})
+	}
+	container.Env = env
+
+	for _, source := range o.EnvFrom {
+		diff.add(container.Name, "envFrom", "", envFromName(source))
+		container.EnvFrom = append(container.EnvFrom, source)
+	}
+}
+
+// commandLine describes the command and args of a container for the diff
+func commandLine(container *corev1.Container) string {
+	return strings.Join(append(append([]string{}, container.Command...), container.Args...), " ")
+}
+
+// envValue describes the value of an environment variable for the diff
+func envValue(envVar corev1.EnvVar) string {
+	if envVar.ValueFrom != nil {
+		return "<valueFrom>"
+	}
+	return envVar.Value
+}
+
+// envFromName describes an envFrom source for the diff
+func envFromName(source corev1.EnvFromSource) string {
+	if source.ConfigMapRef != nil {
+		return "configMap/" + source.ConfigMapRef.Name
+	}
+	return "secret/" + source.SecretRef.Name
+}
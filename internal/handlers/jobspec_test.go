@@ -0,0 +1,468 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"spawnr/internal/k8s"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// podSpec returns a pod spec with the named containers, each running
+// "serve" and the init container "migrate"
+func podSpec(names ...string) *corev1.PodSpec {
+	spec := &corev1.PodSpec{
+		InitContainers: []corev1.Container{{Name: "migrate", Image: "migrate:1"}},
+	}
+	for _, name := range names {
+		spec.Containers = append(spec.Containers, corev1.Container{
+			Name:    name,
+			Image:   name + ":1",
+			Command: []string{"serve"},
+			Args:    []string{"--port", "8080"},
+		})
+	}
+	return spec
+}
+
+func TestContainerIndex(t *testing.T) {
+	tests := []struct {
+		name      string
+		spec      *corev1.PodSpec
+		container string
+		want      int
+		wantErr   string
+	}{
+		{"first container by default", podSpec("app", "sidecar"), "", 0, ""},
+		{"named container", podSpec("app", "sidecar"), "sidecar", 1, ""},
+		{"unknown container", podSpec("app"), "worker", -1, `container "worker" not found`},
+		{"no containers", podSpec(), "", -1, "deployment has no containers"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := containerIndex(tt.spec, tt.container)
+			if got != tt.want {
+				t.Errorf("containerIndex = %d, want %d", got, tt.want)
+			}
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyContainerOverridesCommand(t *testing.T) {
+	tests := []struct {
+		name        string
+		overrides   JobOverrides
+		wantCommand []string
+		wantArgs    []string
+		wantDiff    specDiff
+	}{
+		{
+			name:        "shell form",
+			overrides:   JobOverrides{Command: "rake db:migrate"},
+			wantCommand: []string{"/bin/sh", "-c"},
+			wantArgs:    []string{"rake db:migrate"},
+			wantDiff:    specDiff{{Container: "app", Field: "command", From: "serve --port 8080", To: "/bin/sh -c rake db:migrate"}},
+		},
+		{
+			name:        "exec form",
+			overrides:   JobOverrides{ExecCommand: []string{"/app/migrate"}, ExecArgs: []string{"up"}},
+			wantCommand: []string{"/app/migrate"},
+			wantArgs:    []string{"up"},
+			wantDiff:    specDiff{{Container: "app", Field: "command", From: "serve --port 8080", To: "/app/migrate up"}},
+		},
+		{
+			name:        "exec args keep the command",
+			overrides:   JobOverrides{ExecArgs: []string{"--port", "9090"}},
+			wantCommand: []string{"serve"},
+			wantArgs:    []string{"--port", "9090"},
+			wantDiff:    specDiff{{Container: "app", Field: "command", From: "serve --port 8080", To: "serve --port 9090"}},
+		},
+		{
+			name:        "no command",
+			overrides:   JobOverrides{},
+			wantCommand: []string{"serve"},
+			wantArgs:    []string{"--port", "8080"},
+			wantDiff:    nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := podSpec("app")
+			var diff specDiff
+			if err := applyContainerOverrides(spec, &tt.overrides, &diff); err != nil {
+				t.Fatalf("applyContainerOverrides failed: %v", err)
+			}
+			container := spec.Containers[0]
+			if !reflect.DeepEqual(container.Command, tt.wantCommand) || !reflect.DeepEqual(container.Args, tt.wantArgs) {
+				t.Errorf("command = %q %q, want %q %q", container.Command, container.Args, tt.wantCommand, tt.wantArgs)
+			}
+			if !reflect.DeepEqual(diff, tt.wantDiff) {
+				t.Errorf("diff = %+v, want %+v", diff, tt.wantDiff)
+			}
+		})
+	}
+}
+
+func TestApplyContainerOverridesDropsContainers(t *testing.T) {
+	spec := podSpec("app", "sidecar")
+	overrides := JobOverrides{
+		Command:             "true",
+		Container:           "sidecar",
+		DropOtherContainers: true,
+		DropInitContainers:  true,
+	}
+	var diff specDiff
+	if err := applyContainerOverrides(spec, &overrides, &diff); err != nil {
+		t.Fatalf("applyContainerOverrides failed: %v", err)
+	}
+
+	if len(spec.Containers) != 1 || spec.Containers[0].Name != "sidecar" || spec.Containers[0].Args[0] != "true" {
+		t.Errorf("containers = %+v, want the sidecar running the command alone", spec.Containers)
+	}
+	if len(spec.InitContainers) != 0 {
+		t.Errorf("init containers = %+v, want none", spec.InitContainers)
+	}
+	want := specDiff{
+		{Container: "sidecar", Field: "command", From: "serve --port 8080", To: "/bin/sh -c true"},
+		{Container: "app", Field: "container", From: "present", To: "removed"},
+		{Container: "migrate", Field: "initContainer", From: "present", To: "removed"},
+	}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("diff = %+v, want %+v", diff, want)
+	}
+}
+
+func TestCreateJobRequestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     CreateJobRequest
+		wantErr string
+	}{
+		{"shell command", CreateJobRequest{JobOverrides: JobOverrides{Command: "true"}}, ""},
+		{"exec command", CreateJobRequest{JobOverrides: JobOverrides{ExecCommand: []string{"true"}}}, ""},
+		{"no command", CreateJobRequest{}, "either command or execCommand/execArgs is required"},
+		{"both forms", CreateJobRequest{JobOverrides: JobOverrides{Command: "true", ExecArgs: []string{"x"}}}, "cannot be combined"},
+		{"unknown name mode", CreateJobRequest{JobOverrides: JobOverrides{Command: "true"}, NameMode: "random"}, "nameMode must be"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.validate()
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("validate() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyEnvOverrides(t *testing.T) {
+	secretRef := &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: "db"},
+		Key:                  "password",
+	}}
+	env := []corev1.EnvVar{
+		{Name: "LOG_LEVEL", Value: "info"},
+		{Name: "DB_PASSWORD", ValueFrom: secretRef},
+		{Name: "DEBUG", Value: "1"},
+		{Name: "PORT", Value: "8080"},
+	}
+
+	tests := []struct {
+		name      string
+		overrides JobOverrides
+		wantEnv   []corev1.EnvVar
+		wantDiff  specDiff
+	}{
+		{
+			name:      "override keeps the order",
+			overrides: JobOverrides{Env: map[string]string{"LOG_LEVEL": "debug"}},
+			wantEnv: []corev1.EnvVar{
+				{Name: "LOG_LEVEL", Value: "debug"},
+				{Name: "DB_PASSWORD", ValueFrom: secretRef},
+				{Name: "DEBUG", Value: "1"},
+				{Name: "PORT", Value: "8080"},
+			},
+			wantDiff: specDiff{{Container: "app", Field: "env.LOG_LEVEL", From: "info", To: "debug"}},
+		},
+		{
+			name:      "override replaces valueFrom",
+			overrides: JobOverrides{Env: map[string]string{"DB_PASSWORD": "test"}},
+			wantEnv: []corev1.EnvVar{
+				{Name: "LOG_LEVEL", Value: "info"},
+				{Name: "DB_PASSWORD", Value: "test"},
+				{Name: "DEBUG", Value: "1"},
+				{Name: "PORT", Value: "8080"},
+			},
+			wantDiff: specDiff{{Container: "app", Field: "env.DB_PASSWORD", From: "<valueFrom>", To: "test"}},
+		},
+		{
+			name:      "same value is no change",
+			overrides: JobOverrides{Env: map[string]string{"PORT": "8080"}},
+			wantEnv:   env,
+			wantDiff:  nil,
+		},
+		{
+			name:      "remove and add sorted",
+			overrides: JobOverrides{RemoveEnv: []string{"DEBUG", "MISSING"}, Env: map[string]string{"B_NEW": "b", "A_NEW": "a"}},
+			wantEnv: []corev1.EnvVar{
+				{Name: "LOG_LEVEL", Value: "info"},
+				{Name: "DB_PASSWORD", ValueFrom: secretRef},
+				{Name: "PORT", Value: "8080"},
+				{Name: "A_NEW", Value: "a"},
+				{Name: "B_NEW", Value: "b"},
+			},
+			wantDiff: specDiff{
+				{Container: "app", Field: "env.DEBUG", From: "1"},
+				{Container: "app", Field: "env.A_NEW", To: "a"},
+				{Container: "app", Field: "env.B_NEW", To: "b"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			container := &corev1.Container{Name: "app", Env: append([]corev1.EnvVar{}, env...)}
+			var diff specDiff
+			applyEnvOverrides(container, &tt.overrides, &diff)
+			if !reflect.DeepEqual(container.Env, tt.wantEnv) {
+				t.Errorf("env = %+v, want %+v", container.Env, tt.wantEnv)
+			}
+			if !reflect.DeepEqual(diff, tt.wantDiff) {
+				t.Errorf("diff = %+v, want %+v", diff, tt.wantDiff)
+			}
+		})
+	}
+}
+
+func TestApplyEnvOverridesEnvFrom(t *testing.T) {
+	container := &corev1.Container{Name: "app"}
+	overrides := JobOverrides{EnvFrom: []corev1.EnvFromSource{
+		{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "settings"}}},
+		{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "credentials"}}},
+	}}
+	var diff specDiff
+	applyEnvOverrides(container, &overrides, &diff)
+
+	if !reflect.DeepEqual(container.EnvFrom, overrides.EnvFrom) {
+		t.Errorf("envFrom = %+v, want %+v", container.EnvFrom, overrides.EnvFrom)
+	}
+	want := specDiff{
+		{Container: "app", Field: "envFrom", To: "configMap/settings"},
+		{Container: "app", Field: "envFrom", To: "secret/credentials"},
+	}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("diff = %+v, want %+v", diff, want)
+	}
+}
+
+func TestApplyContainerOverridesImage(t *testing.T) {
+	tests := []struct {
+		name      string
+		image     string
+		wantImage string
+		wantDiff  specDiff
+	}{
+		{"new image", "app:2", "app:2", specDiff{{Container: "app", Field: "image", From: "app:1", To: "app:2"}}},
+		{"same image", "app:1", "app:1", nil},
+		{"no image", "", "app:1", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := podSpec("app")
+			var diff specDiff
+			if err := applyContainerOverrides(spec, &JobOverrides{Image: tt.image}, &diff); err != nil {
+				t.Fatalf("applyContainerOverrides failed: %v", err)
+			}
+			if spec.Containers[0].Image != tt.wantImage {
+				t.Errorf("image = %q, want %q", spec.Containers[0].Image, tt.wantImage)
+			}
+			if !reflect.DeepEqual(diff, tt.wantDiff) {
+				t.Errorf("diff = %+v, want %+v", diff, tt.wantDiff)
+			}
+		})
+	}
+}
+
+func TestJobOverridesValidateEnv(t *testing.T) {
+	configMap := &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "settings"}}
+	secret := &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "credentials"}}
+	tests := []struct {
+		name      string
+		overrides JobOverrides
+		wantErr   string
+	}{
+		{"valid", JobOverrides{Env: map[string]string{"LOG_LEVEL": "debug"}, RemoveEnv: []string{"DEBUG"}, Image: "app:2"}, ""},
+		{"invalid env name", JobOverrides{Env: map[string]string{"1BAD": "x"}}, `invalid env name "1BAD"`},
+		{"set and removed", JobOverrides{Env: map[string]string{"DEBUG": "1"}, RemoveEnv: []string{"DEBUG"}}, "both set and removed"},
+		{"both sources", JobOverrides{EnvFrom: []corev1.EnvFromSource{{ConfigMapRef: configMap, SecretRef: secret}}}, "not both"},
+		{"no source", JobOverrides{EnvFrom: []corev1.EnvFromSource{{Prefix: "APP_"}}}, "must reference a configMap or a secret"},
+		{"unnamed secret", JobOverrides{EnvFrom: []corev1.EnvFromSource{{SecretRef: &corev1.SecretEnvSource{}}}}, "secretRef.name is required"},
+		{"invalid prefix", JobOverrides{EnvFrom: []corev1.EnvFromSource{{ConfigMapRef: configMap, Prefix: "1-"}}}, "invalid envFrom[0].prefix"},
+		{"invalid image", JobOverrides{Image: "app 2"}, `invalid image "app 2"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.overrides.validate()
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("validate() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSanitizePodTemplate(t *testing.T) {
+	probe := &corev1.Probe{ProbeHandler: corev1.ProbeHandler{Exec: &corev1.ExecAction{Command: []string{"true"}}}}
+	lifecycle := &corev1.Lifecycle{PreStop: &corev1.LifecycleHandler{Exec: &corev1.ExecAction{Command: []string{"sleep", "5"}}}}
+
+	tests := []struct {
+		name       string
+		selector   *metav1.LabelSelector
+		labels     map[string]string
+		wantLabels map[string]string
+		wantDiff   specDiff
+	}{
+		{
+			name: "match labels and expressions",
+			selector: &metav1.LabelSelector{
+				MatchLabels:      map[string]string{"app": "web"},
+				MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "tier", Operator: metav1.LabelSelectorOpExists}},
+			},
+			labels:     map[string]string{"app": "web", "tier": "frontend", "team": "payments", appsv1.DefaultDeploymentUniqueLabelKey: "abc123"},
+			wantLabels: map[string]string{"team": "payments", k8s.DeploymentLabel: "web"},
+			wantDiff: specDiff{
+				{Field: "labels.app", From: "web"},
+				{Field: "labels.pod-template-hash", From: "abc123"},
+				{Field: "labels.tier", From: "frontend"},
+			},
+		},
+		{
+			name:       "no labels",
+			selector:   &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			labels:     nil,
+			wantLabels: map[string]string{k8s.DeploymentLabel: "web"},
+			wantDiff:   nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "web"},
+				Spec:       appsv1.DeploymentSpec{Selector: tt.selector},
+			}
+			template := &corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: tt.labels}}
+			var diff specDiff
+			sanitizePodTemplate(template, deployment, &diff)
+
+			if !reflect.DeepEqual(template.Labels, tt.wantLabels) {
+				t.Errorf("labels = %v, want %v", template.Labels, tt.wantLabels)
+			}
+			if !reflect.DeepEqual(diff, tt.wantDiff) {
+				t.Errorf("diff = %+v, want %+v", diff, tt.wantDiff)
+			}
+		})
+	}
+
+	t.Run("probes and hooks", func(t *testing.T) {
+		template := &corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "migrate", StartupProbe: probe}},
+			Containers: []corev1.Container{
+				{Name: "app", LivenessProbe: probe, ReadinessProbe: probe, Lifecycle: lifecycle},
+				{Name: "sidecar"},
+			},
+		}}
+		var diff specDiff
+		sanitizePodTemplate(template, &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web"}}, &diff)
+
+		for _, container := range append(template.Spec.InitContainers, template.Spec.Containers...) {
+			if container.LivenessProbe != nil || container.ReadinessProbe != nil || container.StartupProbe != nil || container.Lifecycle != nil {
+				t.Errorf("container %s kept its probes or hooks", container.Name)
+			}
+		}
+		want := specDiff{
+			{Container: "migrate", Field: "startupProbe", From: "set"},
+			{Container: "app", Field: "livenessProbe", From: "set"},
+			{Container: "app", Field: "readinessProbe", From: "set"},
+			{Container: "app", Field: "lifecycle", From: "set"},
+		}
+		if !reflect.DeepEqual(diff, want) {
+			t.Errorf("diff = %+v, want %+v", diff, want)
+		}
+	})
+}
+
+func TestSetJobName(t *testing.T) {
+	now := time.Date(2024, 5, 1, 14, 30, 5, 0, time.FixedZone("CEST", 2*60*60))
+	long := strings.Repeat("a", 50) + "-" + strings.Repeat("b", 12)
+
+	tests := []struct {
+		name             string
+		base             string
+		mode             NameMode
+		wantName         string
+		wantGenerateName string
+	}{
+		{"exact", "migrate", NameModeExact, "migrate", ""},
+		{"default is exact", "migrate", "", "migrate", ""},
+		{"generate", "migrate", NameModeGenerate, "", "migrate-"},
+		{"timestamp in UTC", "migrate", NameModeTimestamp, "migrate-20240501-123005", ""},
+		{"generate truncated", long, NameModeGenerate, "", long[:57] + "-"},
+		{"timestamp truncated without double hyphen", long, NameModeTimestamp, strings.Repeat("a", 47) + "-20240501-123005", ""},
+		{"timestamp truncated at a hyphen", strings.Repeat("a", 47) + "-b", NameModeTimestamp, strings.Repeat("a", 47) + "-20240501-123005", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := metav1.ObjectMeta{Name: "previous"}
+			setJobName(&meta, tt.base, tt.mode, now)
+			if meta.Name != tt.wantName || meta.GenerateName != tt.wantGenerateName {
+				t.Errorf("name = %q, generateName = %q, want %q, %q", meta.Name, meta.GenerateName, tt.wantName, tt.wantGenerateName)
+			}
+			// The API server appends 5 characters to generateName
+			if len(meta.Name) > 63 || len(meta.GenerateName)+5 > 63 {
+				t.Errorf("name %q or generateName %q is too long", meta.Name, meta.GenerateName)
+			}
+		})
+	}
+}
+
+func TestTruncateName(t *testing.T) {
+	tests := []struct {
+		name string
+		max  int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"exactly-ten", 11, "exactly-ten"},
+		{"cut-here-please", 9, "cut-here"},
+		{"cut-here-please", 8, "cut-here"},
+		{"a--b", 3, "a"},
+	}
+	for _, tt := range tests {
+		if got := truncateName(tt.name, tt.max); got != tt.want {
+			t.Errorf("truncateName(%q, %d) = %q, want %q", tt.name, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestNameConflictMessage(t *testing.T) {
+	tests := []struct {
+		mode NameMode
+		meta metav1.ObjectMeta
+		want string
+	}{
+		{NameModeExact, metav1.ObjectMeta{Name: "migrate"}, `job migrate already exists, use nameMode "generate" or "timestamp"`},
+		{"", metav1.ObjectMeta{Name: "migrate"}, `job migrate already exists, use nameMode "generate" or "timestamp"`},
+		{NameModeTimestamp, metav1.ObjectMeta{Name: "migrate-20240501-123005"}, `within the same second, use nameMode "generate"`},
+		{NameModeGenerate, metav1.ObjectMeta{GenerateName: "migrate-"}, "the name generated from migrate- is taken"},
+	}
+	for _, tt := range tests {
+		if got := nameConflictMessage(&tt.meta, tt.mode); !strings.Contains(got, tt.want) {
+			t.Errorf("nameConflictMessage(%q) = %q, want it to contain %q", tt.mode, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"spawnr/internal/k8s"
+
+	"github.com/gin-gonic/gin"
+	batchv1 "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RerunJobRequest controls how an existing job is cloned into a new run.
+// The overrides are applied on top of the existing job's spec.
+type RerunJobRequest struct {
+	JobOverrides
+
+	// NameMode must produce a fresh name, defaults to NameModeTimestamp
+	NameMode NameMode `json:"nameMode"`
+
+	// Reason replaces the reason recorded on the original job
+	Reason string `json:"reason"`
+	// RequestedBy records who requested the rerun when no authenticating
+	// proxy provides the user in a request header
+	RequestedBy string `json:"requestedBy"`
+}
+
+// controllerLabels are added to jobs and their pods by the job controller and
+// must not be copied into a new job
+var controllerLabels = []string{
+	"controller-uid",
+	"job-name",
+	batchv1.ControllerUidLabel,
+	batchv1.JobNameLabel,
+}
+
+// cloneJob copies the spec of a job for a new run, dropping the selector,
+// status and the labels generated by the job controller
+func cloneJob(job *batchv1.Job) *batchv1.Job {
+	clone := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   job.Namespace,
+			Labels:      map[string]string{},
+			Annotations: map[string]string{},
+		},
+		Spec: *job.Spec.DeepCopy(),
+	}
+
+	for key, value := range job.Labels {
+		clone.Labels[key] = value
+	}
+	for key, value := range job.Annotations {
+		clone.Annotations[key] = value
+	}
+	// The job tracking annotation is managed by the job controller
+	delete(clone.Annotations, "batch.kubernetes.io/job-tracking")
+
+	// The job controller generates the selector and its labels for the new job
+	clone.Spec.Selector = nil
+	clone.Spec.ManualSelector = nil
+	for _, label := range controllerLabels {
+		delete(clone.Labels, label)
+		delete(clone.Spec.Template.Labels, label)
+	}
+
+	return clone
+}
+
+// annotateRerun links a rerun to the job it was cloned from and records who
+// requested it, keeping the remaining source details of the original job
+func (h *Handlers) annotateRerun(c *gin.Context, clone, original *batchv1.Job, req *RerunJobRequest) {
+	clone.Annotations[k8s.RerunOfAnnotation] = original.Name
+
+	// The requester of the original job did not request the rerun
+	if user := h.requestUser(c, req.RequestedBy); user != "" {
+		clone.Annotations[k8s.RequestedByAnnotation] = user
+		clone.Labels[k8s.CreatedByLabel] = k8s.LabelValue(user)
+	} else {
+		delete(clone.Annotations, k8s.RequestedByAnnotation)
+		delete(clone.Labels, k8s.CreatedByLabel)
+	}
+	if req.Reason != "" {
+		clone.Annotations[k8s.ReasonAnnotation] = req.Reason
+	}
+	if req.hasCommand() {
+		clone.Annotations[k8s.CommandAnnotation] = req.commandLine()
+	}
+	if req.Container != "" {
+		clone.Annotations[k8s.ContainerAnnotation] = req.Container
+	}
+
+	cluster := requestCluster(c)
+	if cluster == "" {
+		cluster = k8s.LocalClusterName
+	}
+	clone.Annotations[k8s.ClusterAnnotation] = cluster
+}
+
+// RerunJob creates a new run of an existing spawnr job under a fresh name,
+// optionally applying overrides to the copied spec
+func (h *Handlers) RerunJob(c *gin.Context) {
+	namespace := c.Param("namespace")
+	name := c.Param("name")
+
+	var req RerunJobRequest
+	// The body is optional
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.NameMode == "" {
+		req.NameMode = NameModeTimestamp
+	}
+	if req.NameMode == NameModeExact {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("nameMode must be %s or %s for a rerun", NameModeGenerate, NameModeTimestamp)})
+		return
+	}
+	if err := req.NameMode.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.JobOverrides.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	raw, err := wantsRaw(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	client, err := h.clientFor(c)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	job, err := client.GetJob(namespace, name)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if job.Labels[k8s.ManagedByLabel] != k8s.ManagedByValue {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("job %s is not managed by spawnr", name)})
+		return
+	}
+
+	clone := cloneJob(job)
+
+	// Derive the new name from the name originally entered so repeated reruns
+	// do not stack suffixes
+	base := job.Name
+	if original := job.Annotations[k8s.OriginalNameAnnotation]; original != "" {
+		base = sanitizeJobName(original)
+	}
+	setJobName(&clone.ObjectMeta, base, req.NameMode, time.Now())
+
+	// Target the container the original job ran its command in by default
+	if req.Container == "" {
+		req.Container = job.Annotations[k8s.ContainerAnnotation]
+	}
+	diff := specDiff{}
+	if err := req.JobOverrides.apply(clone, &diff); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.annotateRerun(c, clone, job, &req)
+
+	createdJob, err := client.CreateJob(namespace, clone)
+	if apierrors.IsAlreadyExists(err) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": fmt.Sprintf("job %s already exists, %s was rerun within the same second, use nameMode %q to rerun it again at once", clone.Name, name, NameModeGenerate),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, CreateJobResponse{
+		Job:     jobResponse(createdJob, raw),
+		Changes: diff,
+	})
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"spawnr/internal/k8s"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCloneJob(t *testing.T) {
+	manualSelector := true
+	backoffLimit := int32(3)
+	original := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "default",
+			Name:            "migrate-20240501-123005",
+			UID:             "uid-1",
+			ResourceVersion: "42",
+			Labels: map[string]string{
+				k8s.ManagedByLabel:         k8s.ManagedByValue,
+				"controller-uid":           "uid-1",
+				"job-name":                 "migrate-20240501-123005",
+				batchv1.ControllerUidLabel: "uid-1",
+				batchv1.JobNameLabel:       "migrate-20240501-123005",
+			},
+			Annotations: map[string]string{
+				k8s.OriginalNameAnnotation:         "migrate",
+				"batch.kubernetes.io/job-tracking": "",
+			},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:   &backoffLimit,
+			ManualSelector: &manualSelector,
+			Selector:       &metav1.LabelSelector{MatchLabels: map[string]string{batchv1.ControllerUidLabel: "uid-1"}},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{
+					k8s.DeploymentLabel:        "web",
+					"controller-uid":           "uid-1",
+					"job-name":                 "migrate-20240501-123005",
+					batchv1.ControllerUidLabel: "uid-1",
+					batchv1.JobNameLabel:       "migrate-20240501-123005",
+				}},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "web:1"}}},
+			},
+		},
+		Status: batchv1.JobStatus{Failed: 1},
+	}
+
+	clone := cloneJob(original)
+
+	if clone.Name != "" || clone.UID != "" || clone.ResourceVersion != "" || clone.Namespace != "default" {
+		t.Errorf("clone meta = %+v, want only the namespace", clone.ObjectMeta)
+	}
+	if want := map[string]string{k8s.ManagedByLabel: k8s.ManagedByValue}; !reflect.DeepEqual(clone.Labels, want) {
+		t.Errorf("labels = %v, want %v", clone.Labels, want)
+	}
+	if want := map[string]string{k8s.OriginalNameAnnotation: "migrate"}; !reflect.DeepEqual(clone.Annotations, want) {
+		t.Errorf("annotations = %v, want %v", clone.Annotations, want)
+	}
+	if want := map[string]string{k8s.DeploymentLabel: "web"}; !reflect.DeepEqual(clone.Spec.Template.Labels, want) {
+		t.Errorf("template labels = %v, want %v", clone.Spec.Template.Labels, want)
+	}
+	if clone.Spec.Selector != nil || clone.Spec.ManualSelector != nil {
+		t.Errorf("selector = %v, manualSelector = %v, want both generated again", clone.Spec.Selector, clone.Spec.ManualSelector)
+	}
+	if clone.Status.Failed != 0 {
+		t.Errorf("status = %+v, want it empty", clone.Status)
+	}
+	if *clone.Spec.BackoffLimit != 3 || clone.Spec.Template.Spec.Containers[0].Image != "web:1" {
+		t.Errorf("spec = %+v, want the original spec", clone.Spec)
+	}
+
+	// The clone must not share maps with the original
+	clone.Labels["extra"] = "1"
+	clone.Spec.Template.Labels["extra"] = "1"
+	clone.Spec.Template.Spec.Containers[0].Image = "web:2"
+	if _, ok := original.Labels["extra"]; ok {
+		t.Errorf("the clone shares its labels with the original")
+	}
+	if _, ok := original.Spec.Template.Labels["extra"]; ok || original.Spec.Template.Spec.Containers[0].Image != "web:1" {
+		t.Errorf("the clone shares its spec with the original")
+	}
+}
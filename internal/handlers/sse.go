@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// sseKeepaliveInterval is how often a comment is written to idle
+// Server-Sent Event streams so proxies do not close them
+const sseKeepaliveInterval = 15 * time.Second
+
+// lastEventIDHeader is sent by browsers reconnecting to a Server-Sent Event
+// stream with the ID of the last event they received
+const lastEventIDHeader = "Last-Event-ID"
+
+// lastEventID returns the ID of the last event a reconnecting client
+// received, the "lastEventId" query parameter can be used instead
+func lastEventID(c *gin.Context) string {
+	if id := c.GetHeader(lastEventIDHeader); id != "" {
+		return id
+	}
+	return c.Query("lastEventId")
+}
+
+// streamSSE writes the events of a channel as Server-Sent Events until the
+// channel is closed, with keepalive comments in between
+func streamSSE[T any](c *gin.Context, events <-chan T, render func(T) sse.Event) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("Access-Control-Allow-Origin", "*")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	keepalive := time.NewTicker(sseKeepaliveInterval)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			c.Render(-1, render(event))
+			c.Writer.Flush()
+
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(c.Writer, ": keepalive\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"time"
+
+	"spawnr/internal/k8s"
+
+	"github.com/gin-gonic/gin"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// The API returns the summaries below by default. The raw Kubernetes objects
+// are returned instead when the request sets ?raw=true.
+
+// legacyAPIKey marks requests made through the unversioned /api prefix
+const legacyAPIKey = "spawnr.legacyAPI"
+
+// LegacyAPI is the middleware of the unversioned /api prefix. Its requests
+// get the response shapes of the API before /api/v1: raw objects unless
+// ?raw=false, a bare array of jobs and the bare created job.
+func LegacyAPI(c *gin.Context) {
+	c.Set(legacyAPIKey, true)
+	c.Next()
+}
+
+// isLegacyAPI reports whether a request was made through the /api prefix
+func isLegacyAPI(c *gin.Context) bool {
+	return c.GetBool(legacyAPIKey)
+}
+
+// wantsRaw reports whether a request asks for raw Kubernetes objects
+func wantsRaw(c *gin.Context) (bool, error) {
+	if c.Query("raw") == "" {
+		return isLegacyAPI(c), nil
+	}
+	return queryBool(c, "raw")
+}
+
+// jobResponse returns the summary of a job, or the raw job when requested
+func jobResponse(job *batchv1.Job, raw bool) any {
+	if raw {
+		return newJobView(job)
+	}
+	return newJobSummary(job)
+}
+
+// NamespaceSummary is the compact form of a namespace
+type NamespaceSummary struct {
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func newNamespaceSummary(namespace *corev1.Namespace) NamespaceSummary {
+	return NamespaceSummary{
+		Name:      namespace.Name,
+		Status:    string(namespace.Status.Phase),
+		CreatedAt: namespace.CreationTimestamp.Time,
+	}
+}
+
+// ContainerSummary is a container of a pod template
+type ContainerSummary struct {
+	Name  string `json:"name"`
+	Image string `json:"image"`
+}
+
+func newContainerSummaries(containers []corev1.Container) []ContainerSummary {
+	summaries := make([]ContainerSummary, 0, len(containers))
+	for _, container := range containers {
+		summaries = append(summaries, ContainerSummary{Name: container.Name, Image: container.Image})
+	}
+	return summaries
+}
+
+// DeploymentSummary is the compact form of a deployment
+type DeploymentSummary struct {
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	Revision  string    `json:"revision,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	Replicas          int32 `json:"replicas"`
+	ReadyReplicas     int32 `json:"readyReplicas"`
+	AvailableReplicas int32 `json:"availableReplicas"`
+
+	Containers     []ContainerSummary `json:"containers"`
+	InitContainers []ContainerSummary `json:"initContainers"`
+}
+
+func newDeploymentSummary(deployment *appsv1.Deployment) DeploymentSummary {
+	var replicas int32 = 1
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+
+	return DeploymentSummary{
+		Namespace:         deployment.Namespace,
+		Name:              deployment.Name,
+		Revision:          deployment.Annotations["deployment.kubernetes.io/revision"],
+		CreatedAt:         deployment.CreationTimestamp.Time,
+		Replicas:          replicas,
+		ReadyReplicas:     deployment.Status.ReadyReplicas,
+		AvailableReplicas: deployment.Status.AvailableReplicas,
+		Containers:        newContainerSummaries(deployment.Spec.Template.Spec.Containers),
+		InitContainers:    newContainerSummaries(deployment.Spec.Template.Spec.InitContainers),
+	}
+}
+
+// JobSummary is the compact form of a job
+type JobSummary struct {
+	// Cluster is the cluster the job was listed from when listing several
+	Cluster    string     `json:"cluster,omitempty"`
+	Namespace  string     `json:"namespace"`
+	Name       string     `json:"name"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	// DurationSeconds is the time the job has been running for, or ran for
+	// once it has finished
+	DurationSeconds int64 `json:"durationSeconds"`
+
+	// Pod counts
+	Active    int32 `json:"active"`
+	Succeeded int32 `json:"succeeded"`
+	Failed    int32 `json:"failed"`
+
+	Containers     []string `json:"containers"`
+	InitContainers []string `json:"initContainers"`
+
+	Source k8s.JobSource `json:"source"`
+}
+
+func newJobSummary(job *batchv1.Job) JobSummary {
+	summary := JobSummary{
+		Namespace:      job.Namespace,
+		Name:           job.Name,
+		Status:         k8s.JobPhaseOf(job),
+		CreatedAt:      job.CreationTimestamp.Time,
+		FinishedAt:     k8s.JobFinishedAt(job),
+		Active:         job.Status.Active,
+		Succeeded:      job.Status.Succeeded,
+		Failed:         job.Status.Failed,
+		Containers:     containerNames(job.Spec.Template.Spec.Containers),
+		InitContainers: containerNames(job.Spec.Template.Spec.InitContainers),
+		Source:         k8s.JobSourceOf(job),
+	}
+
+	if job.Status.StartTime != nil {
+		startedAt := job.Status.StartTime.Time
+		summary.StartedAt = &startedAt
+
+		end := time.Now()
+		if summary.FinishedAt != nil {
+			end = *summary.FinishedAt
+		}
+		summary.DurationSeconds = int64(end.Sub(startedAt).Seconds())
+	}
+
+	return summary
+}
+
+func containerNames(containers []corev1.Container) []string {
+	names := make([]string, 0, len(containers))
+	for _, container := range containers {
+		names = append(names, container.Name)
+	}
+	return names
+}
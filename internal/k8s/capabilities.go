@@ -0,0 +1,88 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+)
+
+// Actions of the UI whose permissions are checked by Capabilities
+const (
+	ActionCreateJobs      = "createJobs"
+	ActionViewLogs        = "viewLogs"
+	ActionDeleteJobs      = "deleteJobs"
+	ActionListDeployments = "listDeployments"
+)
+
+// actionPermission is a permission an action needs
+type actionPermission struct {
+	action     string
+	permission authorizationv1.ResourceAttributes
+}
+
+// actionPermissions are the permissions each action needs in a namespace,
+// following the calls the handlers make
+var actionPermissions = []actionPermission{
+	{ActionCreateJobs, authorizationv1.ResourceAttributes{Verb: "get", Group: "apps", Resource: "deployments"}},
+	{ActionCreateJobs, authorizationv1.ResourceAttributes{Verb: "create", Group: "batch", Resource: "jobs"}},
+	{ActionViewLogs, authorizationv1.ResourceAttributes{Verb: "list", Resource: "pods"}},
+	{ActionViewLogs, authorizationv1.ResourceAttributes{Verb: "get", Resource: "pods", Subresource: "log"}},
+	{ActionDeleteJobs, authorizationv1.ResourceAttributes{Verb: "delete", Group: "batch", Resource: "jobs"}},
+	{ActionDeleteJobs, authorizationv1.ResourceAttributes{Verb: "delete", Resource: "pods"}},
+	{ActionListDeployments, authorizationv1.ResourceAttributes{Verb: "list", Group: "apps", Resource: "deployments"}},
+}
+
+// actionDescriptions complete "cannot ..." in the messages of denied actions
+var actionDescriptions = map[string]string{
+	ActionCreateJobs:      "create jobs",
+	ActionViewLogs:        "view job logs",
+	ActionDeleteJobs:      "delete jobs",
+	ActionListDeployments: "list deployments",
+}
+
+// Capability tells whether the cluster identity of spawnr may perform an
+// action
+type Capability struct {
+	Allowed bool `json:"allowed"`
+	// Missing lists the denied permissions, e.g. "get pods/log"
+	Missing []string `json:"missing,omitempty"`
+	Message string   `json:"message,omitempty"`
+}
+
+// Capabilities checks which actions spawnr may perform in a namespace with
+// one SelfSubjectAccessReview per permission, keyed by action
+func (c *Client) Capabilities(ctx context.Context, namespace string) (map[string]Capability, error) {
+	permissions := make([]authorizationv1.ResourceAttributes, len(actionPermissions))
+	for i, ap := range actionPermissions {
+		permissions[i] = ap.permission
+	}
+
+	missing, err := c.missingPermissions(ctx, namespace, permissions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check permissions in namespace %s: %w", namespace, err)
+	}
+	denied := make(map[string]bool, len(missing))
+	for _, permission := range missing {
+		denied[permission] = true
+	}
+
+	capabilities := make(map[string]Capability, len(actionDescriptions))
+	for _, ap := range actionPermissions {
+		capability := capabilities[ap.action]
+		if name := permissionName(ap.permission); denied[name] {
+			capability.Missing = append(capability.Missing, name)
+		}
+		capabilities[ap.action] = capability
+	}
+	for action, capability := range capabilities {
+		capability.Allowed = len(capability.Missing) == 0
+		if !capability.Allowed {
+			capability.Message = fmt.Sprintf("spawnr cannot %s in namespace %s on this cluster, its identity is missing the permissions: %s",
+				actionDescriptions[action], namespace, strings.Join(capability.Missing, ", "))
+		}
+		capabilities[action] = capability
+	}
+	return capabilities, nil
+}
@@ -0,0 +1,365 @@
+package k8s
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// Cluster types, stored under the type key of a cluster secret. Secrets
+// without a type are EKS clusters.
+const (
+	ClusterTypeEKS               = "eks"
+	ClusterTypeKubeconfig        = "kubeconfig"
+	ClusterTypeToken             = "token"
+	ClusterTypeClientCertificate = "client-certificate"
+	ClusterTypeServiceAccount    = "service-account"
+)
+
+// Keys of the cluster secret data
+const (
+	secretKeyType                 = "type"
+	secretKeyClusterName          = "cluster-name"
+	secretKeyFriendlyName         = "friendly-name"
+	secretKeyEndpoint             = "endpoint"
+	secretKeyCertificateAuthority = "certificate-authority-data"
+	secretKeyRoleArn              = "role-arn"
+	secretKeyKubeconfig           = "kubeconfig"
+	secretKeyContext              = "context"
+	secretKeyToken                = "token"
+	secretKeyClientCertificate    = "client-certificate-data"
+	secretKeyClientKey            = "client-key-data"
+)
+
+// ClusterCredentials describes how to connect to a remote cluster. Which
+// fields are required depends on the type:
+//
+//   - eks: roleArn and endpoint, certificateAuthority is fetched when missing
+//   - kubeconfig: kubeconfig, and context unless its current-context is used
+//   - token: endpoint and token
+//   - client-certificate: endpoint, clientCertificate and clientKey
+//   - service-account: endpoint, token and certificateAuthority, as found in
+//     a service account token secret of the remote cluster
+//
+// Certificates and keys are PEM encoded, optionally base64 encoded on top
+// like the *-data fields of a kubeconfig. Without a certificate authority
+// the server certificate is not verified.
+type ClusterCredentials struct {
+	Type                 string `json:"type"`
+	ClusterName          string `json:"clusterName"`
+	FriendlyName         string `json:"friendlyName"`
+	Endpoint             string `json:"endpoint"`
+	CertificateAuthority string `json:"certificateAuthority"`
+	RoleArn              string `json:"roleArn"`
+	Kubeconfig           string `json:"kubeconfig"`
+	Context              string `json:"context"`
+	Token                string `json:"token"`
+	ClientCertificate    string `json:"clientCertificate"`
+	ClientKey            string `json:"clientKey"`
+}
+
+// clusterCredentialsFromSecret reads the credentials of a cluster secret
+func clusterCredentialsFromSecret(secret *corev1.Secret) *ClusterCredentials {
+	creds := &ClusterCredentials{
+		Type:                 string(secret.Data[secretKeyType]),
+		ClusterName:          string(secret.Data[secretKeyClusterName]),
+		FriendlyName:         string(secret.Data[secretKeyFriendlyName]),
+		Endpoint:             string(secret.Data[secretKeyEndpoint]),
+		CertificateAuthority: string(secret.Data[secretKeyCertificateAuthority]),
+		RoleArn:              string(secret.Data[secretKeyRoleArn]),
+		Kubeconfig:           string(secret.Data[secretKeyKubeconfig]),
+		Context:              string(secret.Data[secretKeyContext]),
+		Token:                string(secret.Data[secretKeyToken]),
+		ClientCertificate:    string(secret.Data[secretKeyClientCertificate]),
+		ClientKey:            string(secret.Data[secretKeyClientKey]),
+	}
+	if creds.Type == "" {
+		creds.Type = ClusterTypeEKS
+	}
+	if creds.ClusterName == "" {
+		creds.ClusterName = secret.Name
+	}
+	if creds.FriendlyName == "" {
+		creds.FriendlyName = creds.ClusterName
+	}
+	return creds
+}
+
+// secretData returns the cluster secret data holding the credentials
+func (c *ClusterCredentials) secretData() map[string][]byte {
+	fields := map[string]string{
+		secretKeyType:                 c.Type,
+		secretKeyClusterName:          c.ClusterName,
+		secretKeyFriendlyName:         c.FriendlyName,
+		secretKeyEndpoint:             c.Endpoint,
+		secretKeyCertificateAuthority: c.CertificateAuthority,
+		secretKeyRoleArn:              c.RoleArn,
+		secretKeyKubeconfig:           c.Kubeconfig,
+		secretKeyContext:              c.Context,
+		secretKeyToken:                c.Token,
+		secretKeyClientCertificate:    c.ClientCertificate,
+		secretKeyClientKey:            c.ClientKey,
+	}
+
+	data := map[string][]byte{}
+	for key, value := range fields {
+		if value != "" {
+			data[key] = []byte(value)
+		}
+	}
+	return data
+}
+
+// Validate checks that the fields required by the cluster type are set and
+// well-formed. An empty type defaults to eks.
+func (c *ClusterCredentials) Validate() error {
+	if c.Type == "" {
+		c.Type = ClusterTypeEKS
+	}
+	c.Endpoint = strings.TrimSpace(c.Endpoint)
+	c.CertificateAuthority = strings.TrimSpace(c.CertificateAuthority)
+	c.Token = strings.TrimSpace(c.Token)
+
+	if c.ClusterName == "" || c.FriendlyName == "" {
+		return fmt.Errorf("clusterName and friendlyName are required")
+	}
+	if c.ClusterName == "local" {
+		return fmt.Errorf("clusterName %q is reserved for the local cluster", c.ClusterName)
+	}
+	// The cluster name is the name of its secret
+	if errs := validation.IsDNS1123Subdomain(c.ClusterName); len(errs) > 0 {
+		return fmt.Errorf("invalid clusterName %q: %s", c.ClusterName, strings.Join(errs, ", "))
+	}
+
+	switch c.Type {
+	case ClusterTypeEKS:
+		if c.RoleArn == "" {
+			return fmt.Errorf("roleArn is required for %s clusters", c.Type)
+		}
+	case ClusterTypeKubeconfig:
+		if c.Kubeconfig == "" {
+			return fmt.Errorf("kubeconfig is required for %s clusters", c.Type)
+		}
+		server, err := c.kubeconfigServer()
+		if err != nil {
+			return err
+		}
+		c.Endpoint = server
+		return nil
+	case ClusterTypeToken:
+		if c.Token == "" {
+			return fmt.Errorf("token is required for %s clusters", c.Type)
+		}
+	case ClusterTypeClientCertificate:
+		if c.ClientCertificate == "" || c.ClientKey == "" {
+			return fmt.Errorf("clientCertificate and clientKey are required for %s clusters", c.Type)
+		}
+		certificate, err := decodePEMData("clientCertificate", c.ClientCertificate)
+		if err != nil {
+			return err
+		}
+		key, err := decodePEMData("clientKey", c.ClientKey)
+		if err != nil {
+			return err
+		}
+		if _, err := tls.X509KeyPair(certificate, key); err != nil {
+			return fmt.Errorf("clientCertificate and clientKey do not form a key pair: %w", err)
+		}
+	case ClusterTypeServiceAccount:
+		if c.Token == "" || c.CertificateAuthority == "" {
+			return fmt.Errorf("token and certificateAuthority are required for %s clusters", c.Type)
+		}
+		if strings.Count(c.Token, ".") != 2 {
+			return fmt.Errorf("token must be a service account token (JWT)")
+		}
+	default:
+		return fmt.Errorf("invalid type %q, must be one of %s, %s, %s, %s or %s", c.Type,
+			ClusterTypeEKS, ClusterTypeKubeconfig, ClusterTypeToken, ClusterTypeClientCertificate, ClusterTypeServiceAccount)
+	}
+
+	if c.Endpoint == "" {
+		return fmt.Errorf("endpoint is required for %s clusters", c.Type)
+	}
+	endpoint, err := url.Parse(c.Endpoint)
+	if err != nil || (endpoint.Scheme != "https" && endpoint.Scheme != "http") || endpoint.Host == "" {
+		return fmt.Errorf("endpoint must be an http(s) URL, e.g. https://10.0.0.1:6443")
+	}
+	if c.CertificateAuthority != "" {
+		if _, err := decodePEMData("certificateAuthority", c.CertificateAuthority); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restConfig creates a Kubernetes config from the credentials
+func (c *ClusterCredentials) restConfig() (*rest.Config, error) {
+	switch c.Type {
+	case ClusterTypeEKS:
+		return c.eksRestConfig()
+	case ClusterTypeKubeconfig:
+		config, contextName, err := c.loadKubeconfig()
+		if err != nil {
+			return nil, err
+		}
+		return clientcmd.NewNonInteractiveClientConfig(*config, contextName, &clientcmd.ConfigOverrides{}, nil).ClientConfig()
+	case ClusterTypeToken, ClusterTypeServiceAccount:
+		cluster, err := c.clientcmdCluster()
+		if err != nil {
+			return nil, err
+		}
+		return buildRestConfig(c.ClusterName, cluster, &clientcmdapi.AuthInfo{Token: c.Token})
+	case ClusterTypeClientCertificate:
+		cluster, err := c.clientcmdCluster()
+		if err != nil {
+			return nil, err
+		}
+		certificate, err := decodePEMData("clientCertificate", c.ClientCertificate)
+		if err != nil {
+			return nil, err
+		}
+		key, err := decodePEMData("clientKey", c.ClientKey)
+		if err != nil {
+			return nil, err
+		}
+		return buildRestConfig(c.ClusterName, cluster, &clientcmdapi.AuthInfo{
+			ClientCertificateData: certificate,
+			ClientKeyData:         key,
+		})
+	default:
+		return nil, fmt.Errorf("unsupported cluster type %q", c.Type)
+	}
+}
+
+// eksRestConfig creates a Kubernetes config authenticating with EKS tokens
+func (c *ClusterCredentials) eksRestConfig() (*rest.Config, error) {
+	// EKS tokens expire after 15 minutes, so the token is cached and
+	// refreshed by the transport rather than baked into the config. Fetching
+	// the first one now reports credential problems when switching clusters.
+	tokens := newTokenCache(c.ClusterName, eksTokenFetcher(c.ClusterName, regionFromEndpoint(c.Endpoint), c.RoleArn))
+	if _, err := tokens.Token(); err != nil {
+		fmt.Printf("[eksRestConfig] ERROR: %v\n", err)
+		return nil, err
+	}
+
+	cluster, err := c.clientcmdCluster()
+	if err != nil {
+		return nil, err
+	}
+
+	// The token is set by the transport
+	config, err := buildRestConfig(c.ClusterName, cluster, &clientcmdapi.AuthInfo{})
+	if err != nil {
+		return nil, err
+	}
+	config.WrapTransport = tokens.WrapTransport
+	return config, nil
+}
+
+// clientcmdCluster returns the endpoint of the cluster, verified with its
+// certificate authority when there is one
+func (c *ClusterCredentials) clientcmdCluster() (*clientcmdapi.Cluster, error) {
+	cluster := &clientcmdapi.Cluster{Server: c.Endpoint}
+
+	if c.CertificateAuthority != "" {
+		certificateAuthority, err := decodePEMData("certificateAuthority", c.CertificateAuthority)
+		if err != nil {
+			return nil, err
+		}
+		cluster.CertificateAuthorityData = certificateAuthority
+		fmt.Printf("[clientcmdCluster] Using CA certificate for TLS verification\n")
+	} else {
+		cluster.InsecureSkipTLSVerify = true
+		fmt.Printf("[clientcmdCluster] WARNING: No CA certificate for %s, using insecure TLS\n", c.ClusterName)
+	}
+	return cluster, nil
+}
+
+// buildRestConfig creates a Kubernetes config from a single cluster and user
+func buildRestConfig(name string, cluster *clientcmdapi.Cluster, authInfo *clientcmdapi.AuthInfo) (*rest.Config, error) {
+	config := clientcmdapi.Config{
+		Clusters:       map[string]*clientcmdapi.Cluster{name: cluster},
+		AuthInfos:      map[string]*clientcmdapi.AuthInfo{name: authInfo},
+		Contexts:       map[string]*clientcmdapi.Context{name: {Cluster: name, AuthInfo: name}},
+		CurrentContext: name,
+	}
+	return clientcmd.NewDefaultClientConfig(config, &clientcmd.ConfigOverrides{}).ClientConfig()
+}
+
+// loadKubeconfig parses the kubeconfig and returns it along with the context
+// to use. The kubeconfig is run by spawnr rather than the user who added it,
+// so it must be self-contained and must not run credential plugins.
+func (c *ClusterCredentials) loadKubeconfig() (*clientcmdapi.Config, string, error) {
+	config, err := clientcmd.Load([]byte(c.Kubeconfig))
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid kubeconfig: %w", err)
+	}
+
+	contextName := c.Context
+	if contextName == "" {
+		contextName = config.CurrentContext
+	}
+	if contextName == "" {
+		return nil, "", fmt.Errorf("kubeconfig has no current-context, set context to one of its contexts")
+	}
+
+	kubeContext, ok := config.Contexts[contextName]
+	if !ok {
+		return nil, "", fmt.Errorf("context %q not found in kubeconfig", contextName)
+	}
+	cluster, ok := config.Clusters[kubeContext.Cluster]
+	if !ok {
+		return nil, "", fmt.Errorf("cluster %q of context %q not found in kubeconfig", kubeContext.Cluster, contextName)
+	}
+	if cluster.CertificateAuthority != "" {
+		return nil, "", fmt.Errorf("cluster %q must embed certificate-authority-data instead of referencing a file", kubeContext.Cluster)
+	}
+
+	if authInfo, ok := config.AuthInfos[kubeContext.AuthInfo]; ok {
+		if authInfo.Exec != nil || authInfo.AuthProvider != nil {
+			return nil, "", fmt.Errorf("user %q uses an exec or auth-provider plugin, which is not supported, use a token or client certificate", kubeContext.AuthInfo)
+		}
+		if authInfo.ClientCertificate != "" || authInfo.ClientKey != "" || authInfo.TokenFile != "" {
+			return nil, "", fmt.Errorf("user %q must embed its credentials instead of referencing files", kubeContext.AuthInfo)
+		}
+	}
+
+	return config, contextName, nil
+}
+
+// kubeconfigServer validates the kubeconfig and returns the server of the
+// selected context
+func (c *ClusterCredentials) kubeconfigServer() (string, error) {
+	config, contextName, err := c.loadKubeconfig()
+	if err != nil {
+		return "", err
+	}
+	return config.Clusters[config.Contexts[contextName].Cluster].Server, nil
+}
+
+// decodePEMData decodes PEM data that may be base64 encoded on top
+func decodePEMData(field, value string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+	data := []byte(value)
+	if !strings.HasPrefix(value, "-----BEGIN") {
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be PEM or base64 encoded PEM", field)
+		}
+		data = decoded
+	}
+
+	if block, _ := pem.Decode(data); block == nil {
+		return nil, fmt.Errorf("%s does not contain a PEM block", field)
+	}
+	return data, nil
+}
@@ -0,0 +1,160 @@
+package k8s
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testKeyPair returns a self-signed PEM certificate and its PEM key
+func testKeyPair(t *testing.T) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "spawnr"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
+func TestDecodePEMData(t *testing.T) {
+	certificate, _ := testKeyPair(t)
+	tests := []struct {
+		name    string
+		value   string
+		wantErr string
+	}{
+		{"PEM", certificate, ""},
+		{"PEM with surrounding whitespace", "\n  " + certificate + "  \n", ""},
+		{"base64 encoded PEM", base64.StdEncoding.EncodeToString([]byte(certificate)), ""},
+		{"not base64", "not a certificate!", "certificateAuthority must be PEM or base64 encoded PEM"},
+		{"base64 without PEM", base64.StdEncoding.EncodeToString([]byte("plain text")), "certificateAuthority does not contain a PEM block"},
+		{"truncated PEM", certificate[:40], "certificateAuthority does not contain a PEM block"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := decodePEMData("certificateAuthority", tt.value)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodePEMData failed: %v", err)
+			}
+			if strings.TrimSpace(string(data)) != strings.TrimSpace(certificate) {
+				t.Errorf("data = %q, want the PEM certificate", data)
+			}
+		})
+	}
+}
+
+func TestClusterCredentialsValidate(t *testing.T) {
+	certificate, key := testKeyPair(t)
+	_, otherKey := testKeyPair(t)
+	kubeconfig := `apiVersion: v1
+kind: Config
+current-context: prod
+clusters:
+- name: prod
+  cluster:
+    server: https://10.0.0.1:6443
+contexts:
+- name: prod
+  context:
+    cluster: prod
+    user: spawnr
+users:
+- name: spawnr
+  user:
+    token: secret
+`
+	valid := func(credentials ClusterCredentials) ClusterCredentials {
+		credentials.ClusterName = "prod"
+		credentials.FriendlyName = "Production"
+		return credentials
+	}
+
+	tests := []struct {
+		name        string
+		credentials ClusterCredentials
+		wantErr     string
+	}{
+		{"eks by default", valid(ClusterCredentials{Endpoint: "https://abc.eu-west-1.eks.amazonaws.com", RoleArn: "arn:aws:iam::123456789012:role/spawnr"}), ""},
+		{"eks without role", valid(ClusterCredentials{Type: ClusterTypeEKS, Endpoint: "https://abc.eu-west-1.eks.amazonaws.com"}), "roleArn is required"},
+		{"kubeconfig", valid(ClusterCredentials{Type: ClusterTypeKubeconfig, Kubeconfig: kubeconfig}), ""},
+		{"kubeconfig with unknown context", valid(ClusterCredentials{Type: ClusterTypeKubeconfig, Kubeconfig: kubeconfig, Context: "staging"}), `context "staging" not found`},
+		{"token", valid(ClusterCredentials{Type: ClusterTypeToken, Endpoint: " https://10.0.0.1:6443 ", Token: "secret"}), ""},
+		{"token without endpoint", valid(ClusterCredentials{Type: ClusterTypeToken, Token: "secret"}), "endpoint is required"},
+		{"token with invalid endpoint", valid(ClusterCredentials{Type: ClusterTypeToken, Endpoint: "10.0.0.1:6443", Token: "secret"}), "endpoint must be an http(s) URL"},
+		{"token with invalid certificate authority", valid(ClusterCredentials{Type: ClusterTypeToken, Endpoint: "https://10.0.0.1", Token: "secret", CertificateAuthority: "bogus"}), "certificateAuthority must be PEM"},
+		{"client certificate", valid(ClusterCredentials{Type: ClusterTypeClientCertificate, Endpoint: "https://10.0.0.1", ClientCertificate: certificate, ClientKey: base64.StdEncoding.EncodeToString([]byte(key))}), ""},
+		{"client certificate with another key", valid(ClusterCredentials{Type: ClusterTypeClientCertificate, Endpoint: "https://10.0.0.1", ClientCertificate: certificate, ClientKey: otherKey}), "do not form a key pair"},
+		{"client certificate without key", valid(ClusterCredentials{Type: ClusterTypeClientCertificate, Endpoint: "https://10.0.0.1", ClientCertificate: certificate}), "clientCertificate and clientKey are required"},
+		{"service account", valid(ClusterCredentials{Type: ClusterTypeServiceAccount, Endpoint: "https://10.0.0.1", Token: "header.payload.signature", CertificateAuthority: certificate}), ""},
+		{"service account with opaque token", valid(ClusterCredentials{Type: ClusterTypeServiceAccount, Endpoint: "https://10.0.0.1", Token: "secret", CertificateAuthority: certificate}), "must be a service account token"},
+		{"unknown type", valid(ClusterCredentials{Type: "gke", Endpoint: "https://10.0.0.1"}), `invalid type "gke"`},
+		{"missing names", ClusterCredentials{Type: ClusterTypeToken, Endpoint: "https://10.0.0.1", Token: "secret"}, "clusterName and friendlyName are required"},
+		{"reserved name", ClusterCredentials{ClusterName: "local", FriendlyName: "Local", Type: ClusterTypeToken, Endpoint: "https://10.0.0.1", Token: "secret"}, "reserved"},
+		{"invalid name", ClusterCredentials{ClusterName: "Prod_1", FriendlyName: "Prod", Type: ClusterTypeToken, Endpoint: "https://10.0.0.1", Token: "secret"}, `invalid clusterName "Prod_1"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.credentials.Validate()
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Validate() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClusterCredentialsValidateNormalizes(t *testing.T) {
+	credentials := ClusterCredentials{
+		ClusterName:  "prod",
+		FriendlyName: "Production",
+		Endpoint:     "  https://abc.eu-west-1.eks.amazonaws.com\n",
+		RoleArn:      "arn:aws:iam::123456789012:role/spawnr",
+	}
+	if err := credentials.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if credentials.Type != ClusterTypeEKS || credentials.Endpoint != "https://abc.eu-west-1.eks.amazonaws.com" {
+		t.Errorf("type = %q, endpoint = %q, want eks and the trimmed endpoint", credentials.Type, credentials.Endpoint)
+	}
+
+	kubeconfig := ClusterCredentials{
+		Type:         ClusterTypeKubeconfig,
+		ClusterName:  "prod",
+		FriendlyName: "Production",
+		Kubeconfig:   "apiVersion: v1\nkind: Config\ncurrent-context: prod\nclusters:\n- name: prod\n  cluster:\n    server: https://10.0.0.1:6443\ncontexts:\n- name: prod\n  context:\n    cluster: prod\n",
+	}
+	if err := kubeconfig.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if kubeconfig.Endpoint != "https://10.0.0.1:6443" {
+		t.Errorf("endpoint = %q, want the server of the kubeconfig", kubeconfig.Endpoint)
+	}
+}
@@ -0,0 +1,219 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+)
+
+// diagnosisLogLines is the number of log lines included with a container
+// that exited with a non-zero exit code
+const diagnosisLogLines int64 = 20
+
+// Problem reasons reported by DiagnoseJob
+const (
+	ProblemUnschedulable        = "Unschedulable"
+	ProblemImagePull            = "ImagePullFailure"
+	ProblemOOMKilled            = "OOMKilled"
+	ProblemDeadlineExceeded     = "DeadlineExceeded"
+	ProblemBackoffLimitExceeded = "BackoffLimitExceeded"
+	ProblemNonZeroExit          = "NonZeroExit"
+)
+
+// imagePullReasons are the waiting reasons of a container whose image cannot
+// be pulled
+var imagePullReasons = map[string]bool{
+	"ErrImagePull":      true,
+	"ImagePullBackOff":  true,
+	"InvalidImageName":  true,
+	"ErrImageNeverPull": true,
+}
+
+// Diagnosis summarizes why a job is stuck or has failed
+type Diagnosis struct {
+	Problems []Problem `json:"problems"`
+}
+
+// Problem is one reason a job is stuck or has failed. Pod and Container are
+// empty for problems of the job itself.
+type Problem struct {
+	Reason    string `json:"reason"`
+	Message   string `json:"message,omitempty"`
+	Pod       string `json:"pod,omitempty"`
+	Container string `json:"container,omitempty"`
+	ExitCode  *int32 `json:"exitCode,omitempty"`
+	// LastLogLines holds the end of the logs of a container that exited with
+	// a non-zero exit code
+	LastLogLines []string `json:"lastLogLines,omitempty"`
+}
+
+// newKubernetesEvent converts a core/v1 Event
+func newKubernetesEvent(event *corev1.Event) KubernetesEvent {
+	lastSeen := event.LastTimestamp.Time
+	if lastSeen.IsZero() {
+		lastSeen = event.EventTime.Time
+	}
+	firstSeen := event.FirstTimestamp.Time
+	if firstSeen.IsZero() {
+		firstSeen = lastSeen
+	}
+
+	return KubernetesEvent{
+		Kind:      event.InvolvedObject.Kind,
+		Name:      event.InvolvedObject.Name,
+		Type:      event.Type,
+		Reason:    event.Reason,
+		Message:   event.Message,
+		Count:     event.Count,
+		FirstSeen: firstSeen,
+		LastSeen:  lastSeen,
+	}
+}
+
+// ListJobEvents returns the Kubernetes Events involving a job or its pods,
+// oldest first
+func (c *Client) ListJobEvents(job *batchv1.Job) ([]KubernetesEvent, error) {
+	pods, err := c.ListJobPods(job)
+	if err != nil {
+		return nil, err
+	}
+
+	involved := []fields.Set{{"involvedObject.kind": "Job", "involvedObject.name": job.Name}}
+	for _, pod := range pods {
+		involved = append(involved, fields.Set{"involvedObject.kind": "Pod", "involvedObject.name": pod.Name})
+	}
+
+	var result []KubernetesEvent
+	for _, selector := range involved {
+		events, err := c.clientset.CoreV1().Events(job.Namespace).List(context.TODO(), metav1.ListOptions{
+			FieldSelector: fields.SelectorFromSet(selector).String(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		for i := range events.Items {
+			result = append(result, newKubernetesEvent(&events.Items[i]))
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].LastSeen.Before(result[j].LastSeen)
+	})
+	return result, nil
+}
+
+// DiagnoseJob looks for the usual reasons a job is stuck Pending or has
+// failed: unschedulable pods, image pull failures, OOM kills, exceeded
+// deadlines or backoff limits and containers exiting with a non-zero code
+func (c *Client) DiagnoseJob(job *batchv1.Job) (*Diagnosis, error) {
+	pods, err := c.ListJobPods(job)
+	if err != nil {
+		return nil, err
+	}
+
+	diagnosis := &Diagnosis{Problems: []Problem{}}
+
+	for _, condition := range job.Status.Conditions {
+		if condition.Type != batchv1.JobFailed || condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Reason {
+		case ProblemDeadlineExceeded, ProblemBackoffLimitExceeded:
+			diagnosis.Problems = append(diagnosis.Problems, Problem{
+				Reason:  condition.Reason,
+				Message: condition.Message,
+			})
+		}
+	}
+
+	for i := range pods {
+		diagnosis.Problems = append(diagnosis.Problems, c.diagnosePod(&pods[i])...)
+	}
+
+	return diagnosis, nil
+}
+
+// diagnosePod reports the scheduling and container problems of a job pod
+func (c *Client) diagnosePod(pod *corev1.Pod) []Problem {
+	var problems []Problem
+
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse &&
+			condition.Reason == corev1.PodReasonUnschedulable {
+			problems = append(problems, Problem{
+				Reason:  ProblemUnschedulable,
+				Message: condition.Message,
+				Pod:     pod.Name,
+			})
+		}
+	}
+
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		if waiting := status.State.Waiting; waiting != nil && imagePullReasons[waiting.Reason] {
+			problems = append(problems, Problem{
+				Reason:    ProblemImagePull,
+				Message:   fmt.Sprintf("%s: %s", waiting.Reason, waiting.Message),
+				Pod:       pod.Name,
+				Container: status.Name,
+			})
+		}
+
+		// A restarted container keeps the reason it was last terminated for
+		terminated := status.State.Terminated
+		previous := false
+		if terminated == nil && status.LastTerminationState.Terminated != nil {
+			terminated = status.LastTerminationState.Terminated
+			previous = true
+		}
+		if terminated == nil || terminated.ExitCode == 0 {
+			continue
+		}
+
+		exitCode := terminated.ExitCode
+		problem := Problem{
+			Reason:    ProblemNonZeroExit,
+			Message:   terminated.Message,
+			Pod:       pod.Name,
+			Container: status.Name,
+			ExitCode:  &exitCode,
+		}
+		if terminated.Reason == ProblemOOMKilled {
+			problem.Reason = ProblemOOMKilled
+		} else {
+			if problem.Message == "" {
+				problem.Message = fmt.Sprintf("exited with code %d", exitCode)
+			}
+			problem.LastLogLines = c.lastLogLines(pod, status.Name, previous)
+		}
+		problems = append(problems, problem)
+	}
+
+	return problems
+}
+
+// lastLogLines returns the last lines logged by a container, or nothing when
+// its logs cannot be read
+func (c *Client) lastLogLines(pod *corev1.Pod, container string, previous bool) []string {
+	tailLines := diagnosisLogLines
+	logs, err := c.readContainerLogs(pod.Namespace, pod.Name, container, LogOptions{
+		Previous:  previous,
+		TailLines: &tailLines,
+	})
+	if err != nil {
+		fmt.Printf("[DiagnoseJob] Failed to read logs of %s/%s: %v\n", pod.Name, container, err)
+		return nil
+	}
+
+	logs = strings.TrimRight(logs, "\n")
+	if logs == "" {
+		return nil
+	}
+	return strings.Split(logs, "\n")
+}
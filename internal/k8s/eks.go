@@ -0,0 +1,185 @@
+package k8s
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	ekstypes "github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+const (
+	// awsRequestTimeout bounds every call to the AWS APIs
+	awsRequestTimeout = 30 * time.Second
+
+	// defaultAWSRegion is used when neither the cluster endpoint nor the
+	// environment tell the region
+	defaultAWSRegion = "us-east-1"
+
+	// eksTokenPrefix prefixes the presigned URL of an EKS bearer token
+	eksTokenPrefix = "k8s-aws-v1."
+	// eksClusterIDHeader names the cluster a token is valid for, it is
+	// signed along with the GetCallerIdentity request
+	eksClusterIDHeader = "x-k8s-aws-id"
+	// eksTokenLifetime is how long EKS accepts a token after it was
+	// presigned, minus a margin for clock skew
+	eksTokenLifetime = 14 * time.Minute
+
+	// awsRoleSessionName identifies spawnr in CloudTrail when assuming roles
+	awsRoleSessionName = "spawnr"
+)
+
+// awsConfig loads the AWS configuration from the environment, assuming roleArn
+// when it is set. The STS and EKS endpoints can be overridden with the
+// standard AWS_ENDPOINT_URL_STS and AWS_ENDPOINT_URL_EKS variables, e.g. to
+// point them at a local stand-in.
+func awsConfig(ctx context.Context, region, roleArn string) (aws.Config, error) {
+	var options []func(*config.LoadOptions) error
+	if region != "" {
+		options = append(options, config.WithRegion(region))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	if cfg.Region == "" {
+		cfg.Region = defaultAWSRegion
+	}
+
+	if roleArn != "" {
+		provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), roleArn, func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = awsRoleSessionName
+		})
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+
+	return cfg, nil
+}
+
+// regionFromEndpoint extracts the region of an EKS endpoint of the form
+// https://<hash>.<region>.eks.amazonaws.com, or returns an empty string
+func regionFromEndpoint(endpoint string) string {
+	before, _, found := strings.Cut(endpoint, ".eks.")
+	if !found {
+		return ""
+	}
+	parts := strings.Split(before, ".")
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[len(parts)-1]
+}
+
+// eksTokenFetcher returns a tokenFetcher generating EKS bearer tokens for a
+// cluster with the credentials of roleArn. The AWS config is loaded once, the
+// assumed role credentials are cached and renewed by the SDK.
+func eksTokenFetcher(clusterName, region, roleArn string) tokenFetcher {
+	var cfg *aws.Config
+
+	return func() (string, time.Time, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), awsRequestTimeout)
+		defer cancel()
+
+		if cfg == nil {
+			loaded, err := awsConfig(ctx, region, roleArn)
+			if err != nil {
+				return "", time.Time{}, err
+			}
+			cfg = &loaded
+		}
+		return generateEKSToken(ctx, *cfg, clusterName)
+	}
+}
+
+// generateEKSToken presigns an STS GetCallerIdentity request naming the
+// cluster in the x-k8s-aws-id header. EKS authenticates the bearer of the
+// presigned URL as the identity that signed it.
+func generateEKSToken(ctx context.Context, cfg aws.Config, clusterName string) (string, time.Time, error) {
+	presigner := sts.NewPresignClient(sts.NewFromConfig(cfg))
+	signedAt := time.Now()
+
+	request, err := presigner.PresignGetCallerIdentity(ctx, &sts.GetCallerIdentityInput{}, func(o *sts.PresignOptions) {
+		o.ClientOptions = append(o.ClientOptions, func(o *sts.Options) {
+			o.APIOptions = append(o.APIOptions,
+				smithyhttp.SetHeaderValue(eksClusterIDHeader, clusterName),
+				smithyhttp.SetHeaderValue("X-Amz-Expires", "60"),
+			)
+		})
+	})
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to presign STS GetCallerIdentity: %w", err)
+	}
+
+	token := eksTokenPrefix + base64.RawURLEncoding.EncodeToString([]byte(request.URL))
+	return token, signedAt.Add(eksTokenLifetime), nil
+}
+
+// describeEKSCluster calls the EKS DescribeCluster API, assuming roleArn when
+// it is set
+func describeEKSCluster(clusterName, region, roleArn string) (*ekstypes.Cluster, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), awsRequestTimeout)
+	defer cancel()
+
+	cfg, err := awsConfig(ctx, region, roleArn)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := eks.NewFromConfig(cfg).DescribeCluster(ctx, &eks.DescribeClusterInput{
+		Name: aws.String(clusterName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe EKS cluster %s in %s: %w", clusterName, cfg.Region, err)
+	}
+	if output.Cluster == nil {
+		return nil, fmt.Errorf("EKS cluster %s not found in %s", clusterName, cfg.Region)
+	}
+	return output.Cluster, nil
+}
+
+// fetchClusterCertificate fetches the base64 encoded CA certificate of an EKS
+// cluster
+func fetchClusterCertificate(clusterName, region, roleArn string) (string, error) {
+	cluster, err := describeEKSCluster(clusterName, region, roleArn)
+	if err != nil {
+		return "", err
+	}
+
+	if cluster.CertificateAuthority == nil || aws.ToString(cluster.CertificateAuthority.Data) == "" {
+		return "", fmt.Errorf("no CA certificate returned from AWS")
+	}
+	return aws.ToString(cluster.CertificateAuthority.Data), nil
+}
+
+// GetClusterInfo returns detailed information about a specific EKS cluster in
+// the region of the AWS config, e.g. AWS_REGION
+func GetClusterInfo(clusterName string) (*ClusterInfo, error) {
+	cluster, err := describeEKSCluster(clusterName, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	region := ""
+	if clusterArn, err := arn.Parse(aws.ToString(cluster.Arn)); err == nil {
+		region = clusterArn.Region
+	}
+
+	endpoint := aws.ToString(cluster.Endpoint)
+	return &ClusterInfo{
+		Name:         aws.ToString(cluster.Name),
+		Region:       region,
+		Endpoint:     endpoint,
+		Status:       string(cluster.Status),
+		OriginalName: clusterName,
+	}, nil
+}
@@ -0,0 +1,185 @@
+package k8s
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const assumeRoleResponse = `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleResult>
+    <Credentials>
+      <AccessKeyId>ASIAASSUMEDROLE</AccessKeyId>
+      <SecretAccessKey>assumed-secret</SecretAccessKey>
+      <SessionToken>assumed-session</SessionToken>
+      <Expiration>2099-01-01T00:00:00Z</Expiration>
+    </Credentials>
+    <AssumedRoleUser>
+      <Arn>arn:aws:sts::123456789012:assumed-role/spawnr/spawnr</Arn>
+      <AssumedRoleId>AROAEXAMPLE:spawnr</AssumedRoleId>
+    </AssumedRoleUser>
+  </AssumeRoleResult>
+  <ResponseMetadata>
+    <RequestId>test</RequestId>
+  </ResponseMetadata>
+</AssumeRoleResponse>`
+
+// fakeSTS stands in for STS: it answers AssumeRole, and GetCallerIdentity
+// the way EKS calls it to authenticate a token
+func fakeSTS(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("failed to parse STS request: %v", err)
+		}
+		switch r.Form.Get("Action") {
+		case "AssumeRole":
+			if got := r.Form.Get("RoleSessionName"); got != awsRoleSessionName {
+				t.Errorf("RoleSessionName = %q, want %q", got, awsRoleSessionName)
+			}
+			w.Header().Set("Content-Type", "text/xml")
+			w.Write([]byte(assumeRoleResponse))
+		case "GetCallerIdentity":
+			w.Header().Set("X-Cluster-Id", r.Header.Get(eksClusterIDHeader))
+			w.WriteHeader(http.StatusOK)
+		default:
+			t.Errorf("unexpected STS action %q", r.Form.Get("Action"))
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// isolateAWSEnv points the AWS SDK at the fake STS with static credentials,
+// ignoring the AWS configuration of the machine running the tests
+func isolateAWSEnv(t *testing.T, stsURL string) {
+	missing := filepath.Join(t.TempDir(), "missing")
+	t.Setenv("AWS_CONFIG_FILE", missing)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", missing)
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIASTATIC")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "static-secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	t.Setenv("AWS_ENDPOINT_URL", "")
+	t.Setenv("AWS_ENDPOINT_URL_STS", stsURL)
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+}
+
+// decodeEKSToken returns the presigned URL of an EKS token
+func decodeEKSToken(t *testing.T, token string) *url.URL {
+	encoded, ok := strings.CutPrefix(token, eksTokenPrefix)
+	if !ok {
+		t.Fatalf("token %q lacks the %q prefix", token, eksTokenPrefix)
+	}
+	if strings.ContainsAny(encoded, "=+/") {
+		t.Errorf("token is not unpadded base64url: %q", encoded)
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatalf("failed to decode token: %v", err)
+	}
+	presigned, err := url.Parse(string(decoded))
+	if err != nil {
+		t.Fatalf("failed to parse presigned URL: %v", err)
+	}
+	return presigned
+}
+
+func TestGenerateEKSToken(t *testing.T) {
+	server := fakeSTS(t)
+	isolateAWSEnv(t, server.URL)
+
+	ctx := context.Background()
+	cfg, err := awsConfig(ctx, "eu-west-1", "")
+	if err != nil {
+		t.Fatalf("awsConfig failed: %v", err)
+	}
+
+	before := time.Now()
+	token, expiry, err := generateEKSToken(ctx, cfg, "prod")
+	if err != nil {
+		t.Fatalf("generateEKSToken failed: %v", err)
+	}
+	if want := before.Add(eksTokenLifetime); expiry.Before(want) || expiry.After(want.Add(time.Minute)) {
+		t.Errorf("expiry = %s, want about %s", expiry, want)
+	}
+
+	presigned := decodeEKSToken(t, token)
+	serverURL, _ := url.Parse(server.URL)
+	if presigned.Host != serverURL.Host {
+		t.Errorf("token is presigned for %s, want the overridden endpoint %s", presigned.Host, serverURL.Host)
+	}
+
+	query := presigned.Query()
+	for key, want := range map[string]string{
+		"Action":        "GetCallerIdentity",
+		"Version":       "2011-06-15",
+		"X-Amz-Expires": "60",
+	} {
+		if got := query.Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+	if !strings.Contains(query.Get("X-Amz-SignedHeaders"), eksClusterIDHeader) {
+		t.Errorf("X-Amz-SignedHeaders = %q, want it to include %s", query.Get("X-Amz-SignedHeaders"), eksClusterIDHeader)
+	}
+	if credential := query.Get("X-Amz-Credential"); !strings.HasPrefix(credential, "AKIASTATIC/") || !strings.Contains(credential, "/eu-west-1/sts/") {
+		t.Errorf("X-Amz-Credential = %q, want the static key signing for sts in eu-west-1", credential)
+	}
+
+	// EKS calls the presigned URL with the cluster ID header to authenticate
+	req, err := http.NewRequest(http.MethodGet, presigned.String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(eksClusterIDHeader, "prod")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to call the presigned URL: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("X-Cluster-Id") != "prod" {
+		t.Errorf("presigned GetCallerIdentity answered %d for cluster %q", resp.StatusCode, resp.Header.Get("X-Cluster-Id"))
+	}
+}
+
+func TestEKSTokenFetcherAssumesRole(t *testing.T) {
+	server := fakeSTS(t)
+	isolateAWSEnv(t, server.URL)
+
+	fetch := eksTokenFetcher("prod", "us-west-2", "arn:aws:iam::123456789012:role/spawnr")
+	token, _, err := fetch()
+	if err != nil {
+		t.Fatalf("fetching a token failed: %v", err)
+	}
+
+	query := decodeEKSToken(t, token).Query()
+	if credential := query.Get("X-Amz-Credential"); !strings.HasPrefix(credential, "ASIAASSUMEDROLE/") {
+		t.Errorf("X-Amz-Credential = %q, want the assumed role's key", credential)
+	}
+	if got := query.Get("X-Amz-Security-Token"); got != "assumed-session" {
+		t.Errorf("X-Amz-Security-Token = %q, want the assumed role's session token", got)
+	}
+}
+
+func TestRegionFromEndpoint(t *testing.T) {
+	tests := map[string]string{
+		"https://ABC123.gr7.eu-west-1.eks.amazonaws.com": "eu-west-1",
+		"https://abc.us-east-2.eks.amazonaws.com":        "us-east-2",
+		"https://kubernetes.example.com":                 "",
+		"https://eks.amazonaws.com":                      "",
+	}
+	for endpoint, want := range tests {
+		if got := regionFromEndpoint(endpoint); got != want {
+			t.Errorf("regionFromEndpoint(%q) = %q, want %q", endpoint, got, want)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+)
+
+const (
+	// clusterHealthTimeout bounds a health probe, including creating the
+	// client of the cluster
+	clusterHealthTimeout = 10 * time.Second
+	// clusterHealthTTL is how long the result of a health probe is reused
+	clusterHealthTTL = 30 * time.Second
+)
+
+// Cluster statuses reported by health probes
+const (
+	// ClusterStatusActive means the cluster is ready and spawnr has every
+	// permission it needs
+	ClusterStatusActive = "ACTIVE"
+	// ClusterStatusDegraded means the cluster is reachable but not ready, or
+	// spawnr lacks some permissions or could not check them
+	ClusterStatusDegraded = "DEGRADED"
+	// ClusterStatusUnauthorized means the cluster rejects spawnr's
+	// credentials, or spawnr cannot get any, e.g. because its IAM role was
+	// deleted or cannot be assumed
+	ClusterStatusUnauthorized = "UNAUTHORIZED"
+	// ClusterStatusUnreachable means the cluster did not answer or no client
+	// could be created for it
+	ClusterStatusUnreachable = "UNREACHABLE"
+	// ClusterStatusUnknown means the cluster was not probed
+	ClusterStatusUnknown = "UNKNOWN"
+)
+
+// requiredPermissions are the cluster-wide permissions spawnr uses
+var requiredPermissions = []authorizationv1.ResourceAttributes{
+	{Verb: "list", Resource: "namespaces"},
+	{Verb: "list", Group: "apps", Resource: "deployments"},
+	{Verb: "create", Group: "batch", Resource: "jobs"},
+	{Verb: "list", Group: "batch", Resource: "jobs"},
+	{Verb: "watch", Group: "batch", Resource: "jobs"},
+	{Verb: "delete", Group: "batch", Resource: "jobs"},
+	{Verb: "list", Resource: "pods"},
+	{Verb: "get", Resource: "pods", Subresource: "log"},
+	{Verb: "delete", Resource: "pods"},
+	{Verb: "list", Resource: "events"},
+}
+
+// ClusterHealth is the outcome of a cluster health probe
+type ClusterHealth struct {
+	Status string `json:"status"`
+	// Version is the Kubernetes version of the API server
+	Version string `json:"version,omitempty"`
+	// LatencyMs is the round trip time of the /version request
+	LatencyMs int64 `json:"latencyMs,omitempty"`
+	// MissingPermissions lists the permissions spawnr lacks cluster-wide,
+	// e.g. "create jobs.batch" or "get pods/log"
+	MissingPermissions []string  `json:"missingPermissions,omitempty"`
+	Message            string    `json:"message,omitempty"`
+	CheckedAt          time.Time `json:"checkedAt"`
+}
+
+// CheckHealth probes the API server: /version for reachability, version and
+// latency, /readyz for readiness, and SelfSubjectAccessReviews for the
+// permissions spawnr needs
+func (c *Client) CheckHealth(ctx context.Context) ClusterHealth {
+	health := ClusterHealth{Status: ClusterStatusActive, CheckedAt: time.Now()}
+	restClient := c.clientset.Discovery().RESTClient()
+
+	start := time.Now()
+	body, err := restClient.Get().AbsPath("/version").Do(ctx).Raw()
+	health.LatencyMs = time.Since(start).Milliseconds()
+	switch {
+	case apierrors.IsUnauthorized(err) || isCredentialsError(err):
+		health.Status = ClusterStatusUnauthorized
+		health.Message = err.Error()
+		return health
+	case apierrors.IsForbidden(err):
+		// Some clusters hide their version, the server answered though
+	case err != nil:
+		health.Status = ClusterStatusUnreachable
+		health.Message = err.Error()
+		return health
+	default:
+		var info version.Info
+		if err := json.Unmarshal(body, &info); err == nil {
+			health.Version = info.GitVersion
+		}
+	}
+
+	var problems []string
+	if _, err := restClient.Get().AbsPath("/readyz").Do(ctx).Raw(); err != nil && !apierrors.IsForbidden(err) {
+		health.Status = ClusterStatusDegraded
+		problems = append(problems, fmt.Sprintf("API server is not ready: %v", err))
+	}
+
+	missing, err := c.missingPermissions(ctx, "", requiredPermissions)
+	if err != nil {
+		health.Status = ClusterStatusDegraded
+		problems = append(problems, fmt.Sprintf("failed to check permissions: %v", err))
+	}
+	if len(missing) > 0 {
+		health.Status = ClusterStatusDegraded
+		health.MissingPermissions = missing
+		problems = append(problems, "missing permissions: "+strings.Join(missing, ", "))
+	}
+
+	health.Message = strings.Join(problems, ", ")
+	return health
+}
+
+// missingPermissions runs a SelfSubjectAccessReview per permission
+// concurrently and returns the permissions that are denied, in the order
+// given. An empty namespace checks the permissions cluster-wide.
+func (c *Client) missingPermissions(ctx context.Context, namespace string, permissions []authorizationv1.ResourceAttributes) ([]string, error) {
+	allowed := make([]bool, len(permissions))
+	errs := make([]error, len(permissions))
+
+	var wg sync.WaitGroup
+	for i := range permissions {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			attributes := permissions[i]
+			attributes.Namespace = namespace
+			review, err := c.clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+				Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: &attributes},
+			}, metav1.CreateOptions{})
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			allowed[i] = review.Status.Allowed
+		}(i)
+	}
+	wg.Wait()
+
+	var missing []string
+	for i, permission := range permissions {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		if !allowed[i] {
+			missing = append(missing, permissionName(permission))
+		}
+	}
+	return missing, nil
+}
+
+// permissionName formats a permission like kubectl auth can-i, e.g.
+// "create jobs.batch" or "get pods/log"
+func permissionName(permission authorizationv1.ResourceAttributes) string {
+	resource := permission.Resource
+	if permission.Subresource != "" {
+		resource += "/" + permission.Subresource
+	}
+	if permission.Group != "" {
+		resource += "." + permission.Group
+	}
+	return permission.Verb + " " + resource
+}
+
+// cachedHealth is a health probe result remembered by the ClientPool
+type cachedHealth struct {
+	health  ClusterHealth
+	expires time.Time
+}
+
+// Health probes a cluster, reusing a result younger than clusterHealthTTL
+// unless refresh is set. The probe, including creating the client of the
+// cluster, gives up after clusterHealthTimeout.
+func (p *ClientPool) Health(clusterName string, refresh bool) ClusterHealth {
+	if clusterName == "" {
+		clusterName = LocalClusterName
+	}
+
+	if !refresh {
+		p.mu.Lock()
+		cached, ok := p.health[clusterName]
+		p.mu.Unlock()
+		if ok && time.Now().Before(cached.expires) {
+			return cached.health
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), clusterHealthTimeout)
+	defer cancel()
+
+	done := make(chan ClusterHealth, 1)
+	go func() {
+		client, err := p.Get(clusterName)
+		if err != nil {
+			done <- clientErrorHealth(err)
+			return
+		}
+		done <- client.CheckHealth(ctx)
+	}()
+
+	var health ClusterHealth
+	select {
+	case health = <-done:
+	case <-ctx.Done():
+		health = ClusterHealth{
+			Status:    ClusterStatusUnreachable,
+			Message:   fmt.Sprintf("health check timed out after %s", clusterHealthTimeout),
+			CheckedAt: time.Now(),
+		}
+	}
+
+	if health.Status != ClusterStatusActive {
+		fmt.Printf("[ClientPool] Cluster %s is %s: %s\n", clusterName, health.Status, health.Message)
+	}
+
+	p.mu.Lock()
+	p.health[clusterName] = cachedHealth{health: health, expires: time.Now().Add(clusterHealthTTL)}
+	p.mu.Unlock()
+	return health
+}
+
+// clientErrorHealth is the health of a cluster no client could be created
+// for, telling credential problems apart
+func clientErrorHealth(err error) ClusterHealth {
+	health := ClusterHealth{Status: ClusterStatusUnreachable, Message: err.Error(), CheckedAt: time.Now()}
+	if isCredentialsError(err) {
+		health.Status = ClusterStatusUnauthorized
+	}
+	return health
+}
+
+// CheckClusters probes every cluster concurrently and fills in their health
+func (p *ClientPool) CheckClusters(clusters []ClusterInfo, refresh bool) {
+	var wg sync.WaitGroup
+	for i := range clusters {
+		wg.Add(1)
+		go func(cluster *ClusterInfo) {
+			defer wg.Done()
+			name := cluster.OriginalName
+			if name == "" {
+				name = cluster.Name
+			}
+			cluster.SetHealth(p.Health(name, refresh))
+		}(&clusters[i])
+	}
+	wg.Wait()
+}
+
+// SetHealth copies the outcome of a health probe into the cluster info
+func (i *ClusterInfo) SetHealth(health ClusterHealth) {
+	checkedAt := health.CheckedAt
+	i.Status = health.Status
+	i.Version = health.Version
+	i.LatencyMs = health.LatencyMs
+	i.MissingPermissions = health.MissingPermissions
+	i.StatusMessage = health.Message
+	i.CheckedAt = &checkedAt
+}
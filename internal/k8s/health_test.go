@@ -0,0 +1,135 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const assumeRoleDeniedResponse = `<ErrorResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <Error>
+    <Type>Sender</Type>
+    <Code>AccessDenied</Code>
+    <Message>User is not authorized to perform: sts:AssumeRole</Message>
+  </Error>
+  <RequestId>test</RequestId>
+</ErrorResponse>`
+
+// deniedSTS stands in for STS refusing to let spawnr assume any role
+func deniedSTS(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(assumeRoleDeniedResponse))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// fakeAPIServerClient returns a Client of an API server answering with
+// handler, authenticating with tokens when it is set
+func fakeAPIServerClient(t *testing.T, handler http.HandlerFunc, tokens *tokenCache) *Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	config := &rest.Config{Host: server.URL}
+	if tokens != nil {
+		config.WrapTransport = tokens.WrapTransport
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Client{clientset: clientset, config: config, jobs: newJobCache(clientset)}
+}
+
+func TestIsCredentialsError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"token fetch failure", &credentialsError{cluster: "prod", err: errors.New("AccessDenied")}, true},
+		{"wrapped token fetch failure", &url.Error{Op: "Get", URL: "https://prod/version", Err: &credentialsError{cluster: "prod", err: errors.New("no credentials")}}, true},
+		{"token issuer unreachable", &credentialsError{cluster: "prod", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}, false},
+		{"cluster unreachable", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, false},
+		{"nil", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isCredentialsError(tt.err); got != tt.want {
+				t.Errorf("isCredentialsError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHealthOfClusterWhoseRoleCannotBeAssumed(t *testing.T) {
+	isolateAWSEnv(t, deniedSTS(t).URL)
+	roleArn := "arn:aws:iam::123456789012:role/deleted"
+
+	t.Run("creating the client", func(t *testing.T) {
+		credentials := &ClusterCredentials{
+			Type:        ClusterTypeEKS,
+			ClusterName: "prod",
+			Endpoint:    "https://ABC123.gr7.eu-west-1.eks.amazonaws.com",
+			RoleArn:     roleArn,
+		}
+		_, err := credentials.restConfig()
+		if err == nil {
+			t.Fatal("restConfig succeeded without credentials")
+		}
+		health := clientErrorHealth(fmt.Errorf("failed to create client for cluster prod: %w", err))
+		if health.Status != ClusterStatusUnauthorized || !strings.Contains(health.Message, "AccessDenied") {
+			t.Errorf("health = %s %q, want %s with the STS error", health.Status, health.Message, ClusterStatusUnauthorized)
+		}
+	})
+
+	t.Run("refreshing the token", func(t *testing.T) {
+		tokens := newTokenCache("prod", eksTokenFetcher("prod", "eu-west-1", roleArn))
+		client := fakeAPIServerClient(t, func(w http.ResponseWriter, r *http.Request) {
+			t.Errorf("unexpected request to %s without a token", r.URL.Path)
+		}, tokens)
+
+		health := client.CheckHealth(context.Background())
+		if health.Status != ClusterStatusUnauthorized || !strings.Contains(health.Message, "AccessDenied") {
+			t.Errorf("health = %s %q, want %s with the STS error", health.Status, health.Message, ClusterStatusUnauthorized)
+		}
+	})
+}
+
+func TestCheckHealthIsDegradedWhenPermissionsCannotBeChecked(t *testing.T) {
+	client := fakeAPIServerClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/version":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"gitVersion":"v1.28.4"}`))
+		case "/readyz":
+			w.Write([]byte("ok"))
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","message":"authorizer unavailable","code":500}`))
+		}
+	}, nil)
+
+	health := client.CheckHealth(context.Background())
+	if health.Status != ClusterStatusDegraded {
+		t.Errorf("status = %s, want %s", health.Status, ClusterStatusDegraded)
+	}
+	if health.Version != "v1.28.4" {
+		t.Errorf("version = %q, want v1.28.4", health.Version)
+	}
+	if !strings.Contains(health.Message, "failed to check permissions") {
+		t.Errorf("message = %q, want it to tell the permissions could not be checked", health.Message)
+	}
+}
@@ -0,0 +1,387 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	batchlisters "k8s.io/client-go/listers/batch/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// jobCacheSyncTimeout is how long listing jobs waits for the cache to fill
+const jobCacheSyncTimeout = 30 * time.Second
+
+// watchNamespacesEnv names the namespaces to watch jobs in, separated by
+// commas, when jobs cannot be listed cluster-wide
+const watchNamespacesEnv = "SPAWNR_WATCH_NAMESPACES"
+
+// jobSubscriberBuffer is the number of changes buffered per subscriber, a
+// subscriber falling further behind is dropped
+const jobSubscriberBuffer = 100
+
+// Job change types sent to JobCache subscribers
+const (
+	JobAdded   = "added"
+	JobUpdated = "updated"
+	JobDeleted = "deleted"
+)
+
+// JobChange is a spawnr job being added, updated or deleted
+type JobChange struct {
+	Type string
+	Job  *batchv1.Job
+}
+
+// JobCache keeps the spawnr jobs of a cluster in memory using informers. It
+// watches jobs cluster-wide when allowed to, and falls back to one informer
+// per accessible namespace when RBAC only grants namespaced access, taking
+// the namespaces from SPAWNR_WATCH_NAMESPACES or else from a namespace
+// informer. The informers are started on first use.
+type JobCache struct {
+	clientset kubernetes.Interface
+
+	mu      sync.Mutex
+	started bool
+	stop    chan struct{}
+	// namespaced is true when the jobs are watched per namespace
+	namespaced bool
+	// listers holds one lister per watched namespace, or a single lister for
+	// metav1.NamespaceAll
+	listers map[string]batchlisters.JobLister
+	// informerStops holds the stop channel of the job informer of each
+	// watched namespace, so the informer of a deleted namespace can be stopped
+	informerStops map[string]chan struct{}
+	synced        []cache.InformerSynced
+
+	subscribersMu sync.Mutex
+	subscribers   map[chan JobChange]struct{}
+}
+
+func newJobCache(clientset kubernetes.Interface) *JobCache {
+	return &JobCache{
+		clientset:     clientset,
+		listers:       map[string]batchlisters.JobLister{},
+		informerStops: map[string]chan struct{}{},
+		subscribers:   map[chan JobChange]struct{}{},
+	}
+}
+
+// Subscribe returns a channel receiving every change to the cached jobs,
+// starting the informers if needed, and a function to unsubscribe. The
+// channel is closed when the subscriber falls too far behind or the cache is
+// stopped, the subscriber should then list the jobs again.
+func (jc *JobCache) Subscribe() (<-chan JobChange, func(), error) {
+	if err := jc.start(); err != nil {
+		return nil, nil, err
+	}
+
+	changes := make(chan JobChange, jobSubscriberBuffer)
+	jc.subscribersMu.Lock()
+	jc.subscribers[changes] = struct{}{}
+	jc.subscribersMu.Unlock()
+
+	unsubscribe := func() {
+		jc.subscribersMu.Lock()
+		defer jc.subscribersMu.Unlock()
+		if _, ok := jc.subscribers[changes]; ok {
+			delete(jc.subscribers, changes)
+			close(changes)
+		}
+	}
+	return changes, unsubscribe, nil
+}
+
+// publish sends a change to every subscriber without blocking the informer
+func (jc *JobCache) publish(changeType string, obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	job, ok := obj.(*batchv1.Job)
+	if !ok {
+		return
+	}
+
+	jc.subscribersMu.Lock()
+	defer jc.subscribersMu.Unlock()
+	for changes := range jc.subscribers {
+		select {
+		case changes <- JobChange{Type: changeType, Job: job}:
+		default:
+			fmt.Printf("Warning: dropping job cache subscriber that fell behind\n")
+			delete(jc.subscribers, changes)
+			close(changes)
+		}
+	}
+}
+
+// closeSubscribers closes the channels of every subscriber
+func (jc *JobCache) closeSubscribers() {
+	jc.subscribersMu.Lock()
+	defer jc.subscribersMu.Unlock()
+	for changes := range jc.subscribers {
+		delete(jc.subscribers, changes)
+		close(changes)
+	}
+}
+
+// List returns the cached spawnr jobs sorted by namespace and name, waiting
+// for the cache to fill on first use
+func (jc *JobCache) List() ([]batchv1.Job, error) {
+	if err := jc.start(); err != nil {
+		return nil, err
+	}
+	if err := jc.waitForSync(); err != nil {
+		return nil, err
+	}
+
+	jc.mu.Lock()
+	listers := make([]batchlisters.JobLister, 0, len(jc.listers))
+	for _, lister := range jc.listers {
+		listers = append(listers, lister)
+	}
+	jc.mu.Unlock()
+
+	var jobs []batchv1.Job
+	for _, lister := range listers {
+		cached, err := lister.List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		for _, job := range cached {
+			// Cached objects are shared and must not be modified by callers
+			jobs = append(jobs, *job.DeepCopy())
+		}
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		if jobs[i].Namespace != jobs[j].Namespace {
+			return jobs[i].Namespace < jobs[j].Namespace
+		}
+		return jobs[i].Name < jobs[j].Name
+	})
+	return jobs, nil
+}
+
+// Stop stops the informers, the cache starts them again on the next List
+func (jc *JobCache) Stop() {
+	jc.mu.Lock()
+	defer jc.mu.Unlock()
+
+	if !jc.started {
+		return
+	}
+	close(jc.stop)
+	for _, stop := range jc.informerStops {
+		close(stop)
+	}
+	jc.started = false
+	jc.listers = map[string]batchlisters.JobLister{}
+	jc.informerStops = map[string]chan struct{}{}
+	jc.synced = nil
+	jc.closeSubscribers()
+}
+
+// start starts the informers unless they are running already
+func (jc *JobCache) start() error {
+	jc.mu.Lock()
+	defer jc.mu.Unlock()
+
+	if jc.started {
+		return nil
+	}
+	jc.stop = make(chan struct{})
+
+	// Probe whether jobs can be listed cluster-wide
+	_, err := jc.clientset.BatchV1().Jobs(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: ManagedBySelector,
+		Limit:         1,
+	})
+	switch {
+	case err == nil:
+		jc.namespaced = false
+		jc.watchNamespace(metav1.NamespaceAll, false)
+		fmt.Printf("[JobCache] Watching spawnr jobs cluster-wide\n")
+	case apierrors.IsForbidden(err):
+		fmt.Printf("[JobCache] Not allowed to list jobs cluster-wide, watching them per namespace\n")
+		jc.namespaced = true
+		if err := jc.watchNamespaces(); err != nil {
+			close(jc.stop)
+			return err
+		}
+	default:
+		close(jc.stop)
+		return fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	jc.started = true
+	return nil
+}
+
+// watchNamespace starts a job informer for one namespace, or for all of them
+// with metav1.NamespaceAll. Subscribers are only told about the jobs it
+// lists first with publishInitial, the jobs found while the cache starts are
+// listed by the subscribers anyway. The caller must hold jc.mu.
+func (jc *JobCache) watchNamespace(namespace string, publishInitial bool) {
+	if _, ok := jc.listers[namespace]; ok {
+		return
+	}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(jc.clientset, 0,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = ManagedBySelector
+		}),
+	)
+	jobs := factory.Batch().V1().Jobs()
+	informer := jobs.Informer()
+	if _, err := informer.AddEventHandler(cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			if isInInitialList && !publishInitial {
+				return
+			}
+			jc.publish(JobAdded, obj)
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			jc.publish(JobUpdated, obj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			jc.publish(JobDeleted, obj)
+		},
+	}); err != nil {
+		fmt.Printf("Warning: failed to watch job changes in namespace %q: %v\n", namespace, err)
+	}
+
+	stop := make(chan struct{})
+	jc.listers[namespace] = jobs.Lister()
+	jc.informerStops[namespace] = stop
+	jc.synced = append(jc.synced, informer.HasSynced)
+	factory.Start(stop)
+}
+
+// watchNamespaces starts a job informer for every namespace jobs can be
+// listed in, either the namespaces of SPAWNR_WATCH_NAMESPACES or every
+// namespace of the cluster including those created later on. The caller must
+// hold jc.mu.
+func (jc *JobCache) watchNamespaces() error {
+	if configured := configuredNamespaces(); len(configured) > 0 {
+		for _, namespace := range configured {
+			if jc.canListJobs(namespace) {
+				jc.watchNamespace(namespace, false)
+			}
+		}
+		fmt.Printf("[JobCache] Watching spawnr jobs in the namespaces %s\n", strings.Join(configured, ", "))
+		return nil
+	}
+
+	// The namespace informer needs to list namespaces cluster-wide, which
+	// namespace-scoped identities cannot
+	if _, err := jc.clientset.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{Limit: 1}); err != nil {
+		return fmt.Errorf("failed to list namespaces to watch jobs in, set %s to the namespaces to watch: %w", watchNamespacesEnv, err)
+	}
+
+	factory := informers.NewSharedInformerFactory(jc.clientset, 0)
+	namespaces := factory.Core().V1().Namespaces().Informer()
+	registration, err := namespaces.AddEventHandler(cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			namespace, ok := obj.(*corev1.Namespace)
+			if !ok || !jc.canListJobs(namespace.Name) {
+				return
+			}
+
+			jc.mu.Lock()
+			defer jc.mu.Unlock()
+			if jc.started && jc.namespaced {
+				// Jobs of a namespace created later on are news to subscribers
+				jc.watchNamespace(namespace.Name, !isInInitialList)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			namespace, ok := obj.(*corev1.Namespace)
+			if !ok {
+				return
+			}
+			// Stop the informer so a namespace created again with the same
+			// name does not end up with two informers publishing its jobs
+			jc.mu.Lock()
+			defer jc.mu.Unlock()
+			if stop, ok := jc.informerStops[namespace.Name]; ok {
+				close(stop)
+				delete(jc.informerStops, namespace.Name)
+			}
+			delete(jc.listers, namespace.Name)
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to watch namespaces: %w", err)
+	}
+
+	// The registration has synced once every existing namespace was handled
+	jc.synced = append(jc.synced, registration.HasSynced)
+	factory.Start(jc.stop)
+	return nil
+}
+
+// canListJobs probes whether the jobs of a namespace can be listed, an
+// informer of a namespace they cannot be listed in would keep failing
+func (jc *JobCache) canListJobs(namespace string) bool {
+	_, err := jc.clientset.BatchV1().Jobs(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: ManagedBySelector,
+		Limit:         1,
+	})
+	if err != nil {
+		fmt.Printf("Warning: not watching jobs in namespace %s: %v\n", namespace, err)
+		return false
+	}
+	return true
+}
+
+// configuredNamespaces returns the namespaces of SPAWNR_WATCH_NAMESPACES
+func configuredNamespaces() []string {
+	var namespaces []string
+	for _, namespace := range strings.Split(os.Getenv(watchNamespacesEnv), ",") {
+		if namespace = strings.TrimSpace(namespace); namespace != "" {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	return namespaces
+}
+
+// waitForSync waits until every informer has filled its cache
+func (jc *JobCache) waitForSync() error {
+	ctx, cancel := context.WithTimeout(context.Background(), jobCacheSyncTimeout)
+	defer cancel()
+
+	// Namespace informers add job informers while syncing, so wait until the
+	// set of informers no longer changes
+	for {
+		jc.mu.Lock()
+		synced := append([]cache.InformerSynced{}, jc.synced...)
+		jc.mu.Unlock()
+
+		if !cache.WaitForCacheSync(ctx.Done(), synced...) {
+			return fmt.Errorf("timed out waiting for the job cache to sync")
+		}
+
+		jc.mu.Lock()
+		stable := len(jc.synced) == len(synced)
+		jc.mu.Unlock()
+		if stable {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package k8s
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// namespaceScopedClientset is a fake clientset holding spawnr jobs in the
+// given namespaces, which forbids listing jobs and namespaces cluster-wide
+// like the RBAC of a namespace-scoped identity
+func namespaceScopedClientset(namespaces ...string) *fake.Clientset {
+	var objects []runtime.Object
+	for _, namespace := range namespaces {
+		objects = append(objects, &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      "job",
+			Labels:    map[string]string{ManagedByLabel: ManagedByValue},
+		}})
+	}
+	clientset := fake.NewSimpleClientset(objects...)
+	forbidClusterWide := func(resource schema.GroupResource) k8stesting.ReactionFunc {
+		return func(action k8stesting.Action) (bool, runtime.Object, error) {
+			if action.GetNamespace() != metav1.NamespaceAll {
+				return false, nil, nil
+			}
+			return true, nil, apierrors.NewForbidden(resource, "", nil)
+		}
+	}
+	clientset.PrependReactor("list", "jobs", forbidClusterWide(schema.GroupResource{Group: "batch", Resource: "jobs"}))
+	clientset.PrependReactor("list", "namespaces", forbidClusterWide(schema.GroupResource{Resource: "namespaces"}))
+	return clientset
+}
+
+func TestJobCacheWatchesConfiguredNamespaces(t *testing.T) {
+	t.Setenv(watchNamespacesEnv, " team-a, ,team-b ")
+	jc := newJobCache(namespaceScopedClientset("team-a", "team-b", "team-c"))
+	defer jc.Stop()
+
+	jobs, err := jc.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	var got []string
+	for _, job := range jobs {
+		got = append(got, job.Namespace+"/"+job.Name)
+	}
+	if want := "team-a/job team-b/job"; strings.Join(got, " ") != want {
+		t.Errorf("jobs = %v, want %s", got, want)
+	}
+}
+
+func TestJobCacheRequiresNamespacesWithoutClusterAccess(t *testing.T) {
+	t.Setenv(watchNamespacesEnv, "")
+	jc := newJobCache(namespaceScopedClientset("team-a"))
+	defer jc.Stop()
+
+	_, err := jc.List()
+	if err == nil || !strings.Contains(err.Error(), watchNamespacesEnv) {
+		t.Errorf("err = %v, want an error naming %s", err, watchNamespacesEnv)
+	}
+}
+
+func TestJobCacheDoesNotPublishInitialJobs(t *testing.T) {
+	existing := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "default",
+		Name:      "existing",
+		Labels:    map[string]string{ManagedByLabel: ManagedByValue},
+	}}
+	clientset := fake.NewSimpleClientset(existing)
+	// The fake clientset loses the changes made before the informer watches
+	watching := make(chan struct{})
+	var once sync.Once
+	clientset.PrependWatchReactor("jobs", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		watcher, err := clientset.Tracker().Watch(action.GetResource(), action.GetNamespace())
+		once.Do(func() { close(watching) })
+		return true, watcher, err
+	})
+	jc := newJobCache(clientset)
+	defer jc.Stop()
+
+	changes, unsubscribe, err := jc.Subscribe()
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer unsubscribe()
+	if _, err := jc.List(); err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+
+	<-watching
+	created := existing.DeepCopy()
+	created.Name = "created"
+	if _, err := clientset.BatchV1().Jobs("default").Create(t.Context(), created, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case change := <-changes:
+		if change.Type != JobAdded || change.Job.Name != "created" {
+			t.Errorf("first change = %s %s, want %s created", change.Type, change.Job.Name, JobAdded)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change was published for the created job")
+	}
+}
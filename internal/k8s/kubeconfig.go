@@ -0,0 +1,91 @@
+package k8s
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// KubeContextPrefix prefixes the cluster names of the contexts of the user's
+// kubeconfig, e.g. "context:kind-dev"
+const KubeContextPrefix = "context:"
+
+// localRestConfig returns the config of the cluster spawnr runs in, or of the
+// current context of the user's kubeconfig when running outside a cluster
+func localRestConfig() (*rest.Config, error) {
+	config, err := rest.InClusterConfig()
+	if err == nil {
+		return config, nil
+	}
+
+	// For local development, use kubeconfig
+	config, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		clientcmd.NewDefaultClientConfigLoadingRules(), &clientcmd.ConfigOverrides{},
+	).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes config: %w", err)
+	}
+	return config, nil
+}
+
+// ListKubeconfigContexts returns the contexts of the user's kubeconfig as
+// clusters. The kubeconfig is read from the files listed in KUBECONFIG,
+// merged, or from ~/.kube/config.
+func ListKubeconfigContexts() ([]ClusterInfo, error) {
+	config, err := clientcmd.NewDefaultClientConfigLoadingRules().Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+
+	names := make([]string, 0, len(config.Contexts))
+	for name := range config.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	clusters := make([]ClusterInfo, 0, len(names))
+	for _, name := range names {
+		info := ClusterInfo{
+			Name:         name,
+			Region:       "kubeconfig",
+			Status:       ClusterStatusUnknown,
+			Profile:      "kubeconfig",
+			Type:         "context",
+			OriginalName: KubeContextPrefix + name,
+		}
+		if cluster, ok := config.Clusters[config.Contexts[name].Cluster]; ok {
+			info.Endpoint = cluster.Server
+		}
+		if name == config.CurrentContext {
+			info.Name += " (current)"
+		}
+		clusters = append(clusters, info)
+	}
+	return clusters, nil
+}
+
+// kubeconfigContextConfig creates a Kubernetes config for a context of the
+// user's kubeconfig. Credential plugins of the context, e.g. aws eks
+// get-token, run as the user running spawnr.
+func kubeconfigContextConfig(contextName string) (*rest.Config, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	config, err := rules.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	if _, ok := config.Contexts[contextName]; !ok {
+		return nil, fmt.Errorf("context %q not found in kubeconfig", contextName)
+	}
+
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{
+		CurrentContext: contextName,
+	}).ClientConfig()
+}
+
+// kubeContextName returns the context named by a cluster name, if any
+func kubeContextName(clusterName string) (string, bool) {
+	return strings.CutPrefix(clusterName, KubeContextPrefix)
+}
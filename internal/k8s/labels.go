@@ -0,0 +1,108 @@
+package k8s
+
+import (
+	"encoding/json"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+)
+
+// Labels and annotations spawnr sets on the jobs it creates
+const (
+	// ManagedByLabel marks jobs and pods created by spawnr
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+	ManagedByValue = "spawnr"
+
+	// DeploymentLabel replaces the deployment's selector labels on job pods
+	DeploymentLabel = "spawnr.io/deployment"
+
+	// SourceDeploymentLabel holds the name of the source deployment so jobs can be selected by it
+	SourceDeploymentLabel = "spawnr.io/source-deployment"
+	// CreatedByLabel holds a label-safe form of the user that requested the job
+	CreatedByLabel = "spawnr.io/created-by"
+
+	// SourceDeploymentAnnotation records the namespace/name of the source deployment
+	SourceDeploymentAnnotation = "spawnr.io/source-deployment"
+	// SourceRevisionAnnotation records the revision of the source deployment
+	SourceRevisionAnnotation = "spawnr.io/source-revision"
+	// SourceReplicaSetAnnotation records the ReplicaSet of the source deployment's revision
+	SourceReplicaSetAnnotation = "spawnr.io/source-replicaset"
+	// SourceImagesAnnotation records the image digests of the source pods as a JSON object keyed by container
+	SourceImagesAnnotation = "spawnr.io/source-images"
+	// RequestedByAnnotation records the user that requested the job
+	RequestedByAnnotation = "spawnr.io/requested-by"
+	// OriginalNameAnnotation records the job name as entered, before sanitization
+	OriginalNameAnnotation = "spawnr.io/original-name"
+	// ReasonAnnotation records why the job was created
+	ReasonAnnotation = "spawnr.io/reason"
+	// ClusterAnnotation records the cluster the job was created in
+	ClusterAnnotation = "spawnr.io/cluster"
+	// CommandAnnotation records the command the job runs
+	CommandAnnotation = "spawnr.io/command"
+	// ContainerAnnotation records the container the command runs in
+	ContainerAnnotation = "spawnr.io/container"
+	// RerunOfAnnotation records the name of the job a rerun was cloned from
+	RerunOfAnnotation = "spawnr.io/rerun-of"
+)
+
+// ManagedBySelector selects the jobs created by spawnr
+const ManagedBySelector = ManagedByLabel + "=" + ManagedByValue
+
+// JobSource describes where a spawnr job came from, as recorded in its
+// labels and annotations
+type JobSource struct {
+	Deployment   string            `json:"deployment,omitempty"`
+	Revision     string            `json:"revision,omitempty"`
+	ReplicaSet   string            `json:"replicaSet,omitempty"`
+	Images       map[string]string `json:"images,omitempty"`
+	RequestedBy  string            `json:"requestedBy,omitempty"`
+	OriginalName string            `json:"originalName,omitempty"`
+	Reason       string            `json:"reason,omitempty"`
+	Cluster      string            `json:"cluster,omitempty"`
+	Command      string            `json:"command,omitempty"`
+	Container    string            `json:"container,omitempty"`
+	RerunOf      string            `json:"rerunOf,omitempty"`
+}
+
+// JobSourceOf reads the source tracking annotations of a job
+func JobSourceOf(job *batchv1.Job) JobSource {
+	annotations := job.Annotations
+	source := JobSource{
+		Deployment:   annotations[SourceDeploymentAnnotation],
+		Revision:     annotations[SourceRevisionAnnotation],
+		ReplicaSet:   annotations[SourceReplicaSetAnnotation],
+		RequestedBy:  annotations[RequestedByAnnotation],
+		OriginalName: annotations[OriginalNameAnnotation],
+		Reason:       annotations[ReasonAnnotation],
+		Cluster:      annotations[ClusterAnnotation],
+		Command:      annotations[CommandAnnotation],
+		Container:    annotations[ContainerAnnotation],
+		RerunOf:      annotations[RerunOfAnnotation],
+	}
+
+	if images := annotations[SourceImagesAnnotation]; images != "" {
+		// A malformed annotation only loses the image details
+		_ = json.Unmarshal([]byte(images), &source.Images)
+	}
+
+	return source
+}
+
+// LabelValue shortens a value to fit in a label, which allows at most 63
+// alphanumeric, '-', '_' or '.' characters that must start and end with an
+// alphanumeric character. Other characters are replaced with '_'.
+func LabelValue(value string) string {
+	value = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, value)
+
+	if len(value) > 63 {
+		value = value[:63]
+	}
+	return strings.Trim(value, "-_.")
+}
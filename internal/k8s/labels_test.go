@@ -0,0 +1,36 @@
+package k8s
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func TestLabelValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"valid", "web-api", "web-api"},
+		{"email", "jane.doe@example.com", "jane.doe_example.com"},
+		{"spaces", "Jane Doe", "Jane_Doe"},
+		{"unicode", "zoë smith", "zo__smith"},
+		{"trimmed ends", "-_.web._-", "web"},
+		{"long", strings.Repeat("a", 70), strings.Repeat("a", 63)},
+		{"cut before a separator", strings.Repeat("a", 62) + "-b", strings.Repeat("a", 62)},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := LabelValue(tt.value)
+			if got != tt.want {
+				t.Errorf("LabelValue(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+			if errs := validation.IsValidLabelValue(got); len(errs) > 0 {
+				t.Errorf("LabelValue(%q) = %q is not a valid label value: %v", tt.value, got, errs)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// LogOptions selects the pods and containers GetJobLogs reads and how much
+// of their logs it returns
+type LogOptions struct {
+	// Pod and Container restrict the logs to one pod and/or container
+	Pod       string
+	Container string
+
+	Previous     bool
+	TailLines    *int64
+	SinceSeconds *int64
+	Timestamps   bool
+	LimitBytes   *int64
+}
+
+// ContainerLogs holds the logs of one container of a job pod
+type ContainerLogs struct {
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+	Init      bool   `json:"init"`
+	Logs      string `json:"logs"`
+	// Error is set when the logs of this container could not be read, e.g.
+	// because it has not started yet
+	Error string `json:"error,omitempty"`
+}
+
+// ListJobPods returns the pods of a job, oldest first
+func (c *Client) ListJobPods(job *batchv1.Job) ([]corev1.Pod, error) {
+	selector, err := metav1.LabelSelectorAsSelector(job.Spec.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector on job %s: %w", job.Name, err)
+	}
+
+	pods, err := c.clientset.CoreV1().Pods(job.Namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: selector.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(pods.Items, func(i, j int) bool {
+		return pods.Items[i].CreationTimestamp.Before(&pods.Items[j].CreationTimestamp)
+	})
+	return pods.Items, nil
+}
+
+// GetJobLogs returns the logs of every container, including init containers,
+// of every pod of a job, oldest pod first
+func (c *Client) GetJobLogs(namespace, jobName string, opts LogOptions) ([]ContainerLogs, error) {
+	job, err := c.GetJob(namespace, jobName)
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := c.ListJobPods(job)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []ContainerLogs
+	podFound := false
+	for _, pod := range pods {
+		if opts.Pod != "" && pod.Name != opts.Pod {
+			continue
+		}
+		podFound = true
+
+		for _, container := range podContainers(&pod) {
+			if opts.Container != "" && container.name != opts.Container {
+				continue
+			}
+
+			logs := ContainerLogs{
+				Pod:       pod.Name,
+				Container: container.name,
+				Init:      container.init,
+			}
+			text, err := c.readContainerLogs(namespace, pod.Name, container.name, opts)
+			if err != nil {
+				logs.Error = err.Error()
+			}
+			logs.Logs = text
+			result = append(result, logs)
+		}
+	}
+
+	if opts.Pod != "" && !podFound {
+		return nil, notFoundError("pod %s not found for job %s", opts.Pod, jobName)
+	}
+	if opts.Container != "" && len(result) == 0 && podFound {
+		return nil, notFoundError("container %s not found in the pods of job %s", opts.Container, jobName)
+	}
+
+	return result, nil
+}
+
+// notFoundError returns an error that apierrors.IsNotFound recognizes, for
+// objects selected by the caller that do not exist
+func notFoundError(format string, args ...any) error {
+	return &apierrors.StatusError{ErrStatus: metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    http.StatusNotFound,
+		Reason:  metav1.StatusReasonNotFound,
+		Message: fmt.Sprintf(format, args...),
+	}}
+}
+
+type podContainer struct {
+	name string
+	init bool
+}
+
+// podContainers lists the init containers and containers of a pod in start order
+func podContainers(pod *corev1.Pod) []podContainer {
+	containers := make([]podContainer, 0, len(pod.Spec.InitContainers)+len(pod.Spec.Containers))
+	for _, container := range pod.Spec.InitContainers {
+		containers = append(containers, podContainer{name: container.Name, init: true})
+	}
+	for _, container := range pod.Spec.Containers {
+		containers = append(containers, podContainer{name: container.Name})
+	}
+	return containers
+}
+
+// readContainerLogs reads the logs of one container
+func (c *Client) readContainerLogs(namespace, podName, container string, opts LogOptions) (string, error) {
+	req := c.clientset.CoreV1().Pods(namespace).GetLogs(podName, &corev1.PodLogOptions{
+		Container:    container,
+		Previous:     opts.Previous,
+		TailLines:    opts.TailLines,
+		SinceSeconds: opts.SinceSeconds,
+		Timestamps:   opts.Timestamps,
+		LimitBytes:   opts.LimitBytes,
+	})
+
+	stream, err := req.Stream(context.TODO())
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if closeErr := stream.Close(); closeErr != nil {
+			fmt.Printf("Warning: failed to close stream: %v\n", closeErr)
+		}
+	}()
+
+	logs, err := io.ReadAll(stream)
+	if err != nil {
+		return string(logs), err
+	}
+
+	return string(logs), nil
+}
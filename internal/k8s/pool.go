@@ -0,0 +1,80 @@
+package k8s
+
+import (
+	"fmt"
+	"sync"
+)
+
+// LocalClusterName is the identifier used for the cluster spawnr itself runs in
+const LocalClusterName = "local"
+
+// ClientPool caches one Client per cluster so that each request can target
+// its own cluster without affecting requests made by other users
+type ClientPool struct {
+	mu      sync.Mutex
+	clients map[string]*Client
+	// health caches the latest health probe of each cluster, see Health
+	health map[string]cachedHealth
+}
+
+// NewClientPool creates a pool seeded with the client for the local cluster
+func NewClientPool(local *Client) *ClientPool {
+	return &ClientPool{
+		clients: map[string]*Client{
+			LocalClusterName: local,
+		},
+		health: make(map[string]cachedHealth),
+	}
+}
+
+// Get returns the cached client for a cluster, creating it on first use.
+// An empty cluster name refers to the local cluster.
+func (p *ClientPool) Get(clusterName string) (*Client, error) {
+	if clusterName == "" {
+		clusterName = LocalClusterName
+	}
+
+	p.mu.Lock()
+	client, ok := p.clients[clusterName]
+	p.mu.Unlock()
+	if ok {
+		return client, nil
+	}
+
+	// Build the client without holding the lock, creating a client can take
+	// a while and must not block requests for other clusters
+	client, err := NewClientWithCluster(clusterName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client for cluster %s: %w", clusterName, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Another request may have created the client in the meantime
+	if existing, ok := p.clients[clusterName]; ok {
+		return existing, nil
+	}
+	p.clients[clusterName] = client
+
+	fmt.Printf("[ClientPool] Cached client for cluster %s, server: %s\n", clusterName, client.GetServerURL())
+	return client, nil
+}
+
+// Evict drops the cached client and health of a cluster so the next Get
+// rebuilds it. The local cluster client is never evicted.
+func (p *ClientPool) Evict(clusterName string) {
+	if clusterName == "" || clusterName == LocalClusterName {
+		return
+	}
+
+	p.mu.Lock()
+	client, ok := p.clients[clusterName]
+	delete(p.clients, clusterName)
+	delete(p.health, clusterName)
+	p.mu.Unlock()
+
+	if ok {
+		client.Close()
+	}
+}
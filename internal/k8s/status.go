@@ -0,0 +1,64 @@
+package k8s
+
+import (
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Job phases reported by JobPhaseOf
+const (
+	JobPhasePending   = "Pending"
+	JobPhaseRunning   = "Running"
+	JobPhaseSucceeded = "Succeeded"
+	JobPhaseFailed    = "Failed"
+	JobPhaseSuspended = "Suspended"
+)
+
+// JobFinished reports whether a job has reached its Complete or Failed
+// condition, along with the condition type
+func JobFinished(job *batchv1.Job) (bool, batchv1.JobConditionType) {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		if condition.Type == batchv1.JobComplete || condition.Type == batchv1.JobFailed {
+			return true, condition.Type
+		}
+	}
+	return false, ""
+}
+
+// JobPhaseOf summarizes the state of a job. Only the Complete and Failed
+// conditions are final, failed pods are retried until the backoff limit.
+func JobPhaseOf(job *batchv1.Job) string {
+	switch finished, condition := JobFinished(job); {
+	case finished && condition == batchv1.JobComplete:
+		return JobPhaseSucceeded
+	case finished:
+		return JobPhaseFailed
+	case job.Spec.Suspend != nil && *job.Spec.Suspend:
+		return JobPhaseSuspended
+	case job.Status.Active > 0:
+		return JobPhaseRunning
+	default:
+		return JobPhasePending
+	}
+}
+
+// JobFinishedAt returns when a job completed or failed, or nil while it is
+// still running
+func JobFinishedAt(job *batchv1.Job) *time.Time {
+	if job.Status.CompletionTime != nil {
+		finishedAt := job.Status.CompletionTime.Time
+		return &finishedAt
+	}
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
+			finishedAt := condition.LastTransitionTime.Time
+			return &finishedAt
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package k8s
+
+import (
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestJobPhaseOf(t *testing.T) {
+	suspended := true
+	condition := func(conditionType batchv1.JobConditionType, status corev1.ConditionStatus) batchv1.JobCondition {
+		return batchv1.JobCondition{Type: conditionType, Status: status}
+	}
+
+	tests := []struct {
+		name   string
+		spec   batchv1.JobSpec
+		status batchv1.JobStatus
+		want   string
+	}{
+		{"new", batchv1.JobSpec{}, batchv1.JobStatus{}, JobPhasePending},
+		{"running", batchv1.JobSpec{}, batchv1.JobStatus{Active: 1}, JobPhaseRunning},
+		{"retrying failed pods", batchv1.JobSpec{}, batchv1.JobStatus{Active: 1, Failed: 2}, JobPhaseRunning},
+		{"waiting for a retry", batchv1.JobSpec{}, batchv1.JobStatus{Failed: 1}, JobPhasePending},
+		{"suspended", batchv1.JobSpec{Suspend: &suspended}, batchv1.JobStatus{}, JobPhaseSuspended},
+		{"complete", batchv1.JobSpec{}, batchv1.JobStatus{Succeeded: 1, Conditions: []batchv1.JobCondition{condition(batchv1.JobComplete, corev1.ConditionTrue)}}, JobPhaseSucceeded},
+		{"failed", batchv1.JobSpec{}, batchv1.JobStatus{Failed: 3, Conditions: []batchv1.JobCondition{condition(batchv1.JobFailed, corev1.ConditionTrue)}}, JobPhaseFailed},
+		{"suspended after failing", batchv1.JobSpec{Suspend: &suspended}, batchv1.JobStatus{Conditions: []batchv1.JobCondition{condition(batchv1.JobFailed, corev1.ConditionTrue)}}, JobPhaseFailed},
+		{"condition not true", batchv1.JobSpec{}, batchv1.JobStatus{Active: 1, Conditions: []batchv1.JobCondition{condition(batchv1.JobFailed, corev1.ConditionFalse)}}, JobPhaseRunning},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := &batchv1.Job{Spec: tt.spec, Status: tt.status}
+			if got := JobPhaseOf(job); got != tt.want {
+				t.Errorf("JobPhaseOf = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJobFinishedAt(t *testing.T) {
+	completed := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	failed := completed.Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		status batchv1.JobStatus
+		want   *time.Time
+	}{
+		{"running", batchv1.JobStatus{Active: 1}, nil},
+		{"complete", batchv1.JobStatus{CompletionTime: &metav1.Time{Time: completed}}, &completed},
+		{"failed", batchv1.JobStatus{Conditions: []batchv1.JobCondition{
+			{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, LastTransitionTime: metav1.Time{Time: failed}},
+		}}, &failed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := JobFinishedAt(&batchv1.Job{Status: tt.status})
+			if (got == nil) != (tt.want == nil) || got != nil && !got.Equal(*tt.want) {
+				t.Errorf("JobFinishedAt = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
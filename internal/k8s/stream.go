@@ -0,0 +1,298 @@
+package k8s
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// logPollInterval is how often StreamJobLogs checks for new pods and for
+// containers that have not started yet
+const logPollInterval = 2 * time.Second
+
+// Log stream event kinds
+const (
+	LogEventLine    = "line"
+	LogEventPod     = "pod"
+	LogEventWaiting = "waiting"
+	LogEventEnd     = "end"
+	LogEventError   = "error"
+)
+
+// LogStreamEvent is one line of a followed log stream or a change in what is
+// being streamed
+type LogStreamEvent struct {
+	Type      string `json:"type"`
+	Pod       string `json:"pod,omitempty"`
+	Container string `json:"container,omitempty"`
+	Line      string `json:"line,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+// StreamJobLogs follows the logs of a job's pods one after another, moving on
+// to the next pod when a retry starts. The channel is closed once the job has
+// finished and every pod has been streamed, or when ctx is cancelled.
+func (c *Client) StreamJobLogs(ctx context.Context, namespace, jobName string, opts LogOptions) (<-chan LogStreamEvent, error) {
+	job, err := c.GetJob(namespace, jobName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Follow the container the job runs its command in unless one is requested
+	container := opts.Container
+	if container == "" {
+		container = job.Annotations[ContainerAnnotation]
+	}
+	if container == "" && len(job.Spec.Template.Spec.Containers) > 0 {
+		container = job.Spec.Template.Spec.Containers[0].Name
+	}
+
+	events := make(chan LogStreamEvent, 100)
+
+	go func() {
+		defer close(events)
+
+		send := func(event LogStreamEvent) bool {
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		streamed := map[string]bool{}
+		for {
+			job, err := c.GetJob(namespace, jobName)
+			if err != nil {
+				send(LogStreamEvent{Type: LogEventError, Message: err.Error()})
+				return
+			}
+
+			pods, err := c.ListJobPods(job)
+			if err != nil {
+				send(LogStreamEvent{Type: LogEventError, Message: err.Error()})
+				return
+			}
+
+			// Stream the oldest pod that has not been streamed yet
+			var next *corev1.Pod
+			for i := range pods {
+				if opts.Pod != "" && pods[i].Name != opts.Pod {
+					continue
+				}
+				if !streamed[pods[i].Name] {
+					next = &pods[i]
+					break
+				}
+			}
+
+			if next != nil {
+				if !send(LogStreamEvent{Type: LogEventPod, Pod: next.Name, Container: container}) {
+					return
+				}
+				if !c.followContainer(ctx, next, container, opts, send) {
+					return
+				}
+				streamed[next.Name] = true
+				continue
+			}
+
+			if finished, condition := JobFinished(job); finished || opts.Pod != "" {
+				message := fmt.Sprintf("Job %s", jobName)
+				if condition != "" {
+					message = fmt.Sprintf("Job %s: %s", jobName, condition)
+				}
+				send(LogStreamEvent{Type: LogEventEnd, Message: message})
+				return
+			}
+
+			// Wait for the next pod of the job, e.g. a retry
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(logPollInterval):
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// followContainer streams the logs of one container until it terminates,
+// waiting for it to start first. A stream dropped while the container is
+// still running is reopened from the last line received. It returns false
+// when ctx is cancelled.
+func (c *Client) followContainer(ctx context.Context, pod *corev1.Pod, container string, opts LogOptions, send func(LogStreamEvent) bool) bool {
+	waiting := false
+	var position logPosition
+	for {
+		// Lines are always requested with timestamps to know where to resume
+		logOptions := &corev1.PodLogOptions{
+			Container:  container,
+			Follow:     true,
+			Timestamps: true,
+		}
+		if position.resumed {
+			since := metav1.NewTime(position.last)
+			logOptions.SinceTime = &since
+		} else {
+			logOptions.TailLines = opts.TailLines
+			logOptions.SinceSeconds = opts.SinceSeconds
+		}
+
+		stream, err := c.clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, logOptions).Stream(ctx)
+		if err == nil {
+			sent, scanErr := sendLogLines(stream, pod.Name, container, opts.Timestamps, &position, send)
+			if closeErr := stream.Close(); closeErr != nil {
+				fmt.Printf("Warning: failed to close stream: %v\n", closeErr)
+			}
+			if !sent || ctx.Err() != nil {
+				return false
+			}
+			// The container may wait again, e.g. after a restart
+			if !position.last.IsZero() {
+				waiting = false
+			}
+
+			// The stream also ends when the API server or kubelet drops it
+			if !c.containerRunning(ctx, pod, container) {
+				if scanErr != nil {
+					send(LogStreamEvent{Type: LogEventError, Pod: pod.Name, Container: container, Message: scanErr.Error()})
+				}
+				return true
+			}
+			fmt.Printf("[StreamJobLogs] Log stream of %s/%s dropped while running, reopening it\n", pod.Name, container)
+			position.resume()
+		} else {
+			if ctx.Err() != nil {
+				return false
+			}
+
+			// The container has not started yet, or the pod is gone
+			current, getErr := c.clientset.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+			if getErr != nil || current.Status.Phase == corev1.PodSucceeded || current.Status.Phase == corev1.PodFailed {
+				send(LogStreamEvent{Type: LogEventError, Pod: pod.Name, Container: container, Message: err.Error()})
+				return true
+			}
+			if !waiting {
+				waiting = true
+				if !send(LogStreamEvent{Type: LogEventWaiting, Pod: pod.Name, Container: container, Message: err.Error()}) {
+					return false
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(logPollInterval):
+		}
+	}
+}
+
+// sendLogLines sends the timestamped lines of a log stream that were not sent
+// before, stripping the timestamps unless they were asked for. It returns
+// false when a line could not be sent, along with the error that ended the
+// stream, if any.
+func sendLogLines(stream io.Reader, pod, container string, timestamps bool, position *logPosition, send func(LogStreamEvent) bool) (bool, error) {
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if timestamp, text, ok := splitLogTimestamp(line); ok {
+			if !position.next(timestamp) {
+				continue
+			}
+			if !timestamps {
+				line = text
+			}
+		}
+		if !send(LogStreamEvent{Type: LogEventLine, Pod: pod, Container: container, Line: line}) {
+			return false, nil
+		}
+	}
+	return true, scanner.Err()
+}
+
+// logPosition tracks the last log line sent from a container, so a reopened
+// stream can skip the lines sent already. SinceTime has a precision of
+// seconds and distinct lines can share a timestamp, so the lines sent with
+// the last timestamp are counted.
+type logPosition struct {
+	last time.Time
+	// atLast is the number of lines sent with the timestamp last
+	atLast int
+	// resumed is set once the stream was reopened from last
+	resumed bool
+	// skip is the number of lines with the timestamp last the reopened
+	// stream repeats
+	skip int
+}
+
+// resume prepares for a stream reopened from the last line sent
+func (p *logPosition) resume() {
+	if p.last.IsZero() {
+		return
+	}
+	p.resumed = true
+	p.skip = p.atLast
+}
+
+// next records a line with the given timestamp and reports whether to send
+// it, which is false for lines a reopened stream repeats
+func (p *logPosition) next(timestamp time.Time) bool {
+	switch {
+	case timestamp.After(p.last):
+		p.last = timestamp
+		p.atLast = 1
+		p.skip = 0
+		return true
+	case timestamp.Equal(p.last):
+		if p.skip > 0 {
+			p.skip--
+			return false
+		}
+		p.atLast++
+		return true
+	default:
+		// Only a reopened stream goes back in time, to the start of the second
+		return !p.resumed
+	}
+}
+
+// containerRunning tells whether a container of a pod is still running
+func (c *Client) containerRunning(ctx context.Context, pod *corev1.Pod, container string) bool {
+	current, err := c.clientset.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+	if err != nil {
+		return false
+	}
+	statuses := append(current.Status.InitContainerStatuses, current.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		if status.Name == container {
+			return status.State.Running != nil
+		}
+	}
+	return false
+}
+
+// splitLogTimestamp splits the RFC 3339 timestamp the kubelet prefixes log
+// lines with from the line
+func splitLogTimestamp(line string) (time.Time, string, bool) {
+	prefix, text, ok := strings.Cut(line, " ")
+	if !ok {
+		// Empty lines only carry the timestamp
+		prefix, text = line, ""
+	}
+	timestamp, err := time.Parse(time.RFC3339Nano, prefix)
+	if err != nil {
+		return time.Time{}, line, false
+	}
+	return timestamp, text, true
+}
@@ -0,0 +1,81 @@
+package k8s
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSplitLogTimestamp(t *testing.T) {
+	stamp := time.Date(2024, 5, 1, 12, 30, 0, 123456789, time.UTC)
+	tests := []struct {
+		name      string
+		line      string
+		timestamp time.Time
+		text      string
+		ok        bool
+	}{
+		{"line", "2024-05-01T12:30:00.123456789Z hello world", stamp, "hello world", true},
+		{"empty line", "2024-05-01T12:30:00.123456789Z", stamp, "", true},
+		{"leading spaces kept", "2024-05-01T12:30:00.123456789Z   indented", stamp, "  indented", true},
+		{"no timestamp", "hello world", time.Time{}, "hello world", false},
+		{"empty", "", time.Time{}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timestamp, text, ok := splitLogTimestamp(tt.line)
+			if !timestamp.Equal(tt.timestamp) || text != tt.text || ok != tt.ok {
+				t.Errorf("splitLogTimestamp(%q) = %s, %q, %v, want %s, %q, %v", tt.line, timestamp, text, ok, tt.timestamp, tt.text, tt.ok)
+			}
+		})
+	}
+}
+
+func TestLogPositionSkipsRepeatedLines(t *testing.T) {
+	second := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	at := func(offset int) time.Time { return second.Add(time.Duration(offset) * time.Second) }
+
+	var position logPosition
+	// The first stream sends two lines sharing a timestamp before dropping
+	for i, timestamp := range []time.Time{at(0), at(1), at(1)} {
+		if !position.next(timestamp) {
+			t.Fatalf("line %d of the first stream was not sent", i+1)
+		}
+	}
+
+	// The reopened stream starts from the last timestamp, repeating the lines
+	// sent at it, and goes on with a third line sharing it
+	position.resume()
+	tests := []struct {
+		timestamp time.Time
+		send      bool
+	}{
+		{at(1), false},
+		{at(1), false},
+		{at(1), true},
+		{at(2), true},
+		{at(2), true},
+	}
+	for i, tt := range tests {
+		if got := position.next(tt.timestamp); got != tt.send {
+			t.Errorf("line %d of the reopened stream at %s: send = %v, want %v", i+1, tt.timestamp, got, tt.send)
+		}
+	}
+
+	// Resuming again skips the two lines sent at the new last timestamp
+	position.resume()
+	if position.next(at(1)) || position.next(at(2)) || position.next(at(2)) || !position.next(at(2)) {
+		t.Errorf("second reopened stream did not skip exactly the lines already sent")
+	}
+}
+
+func TestLogPositionKeepsLinesOfFirstStream(t *testing.T) {
+	var position logPosition
+	position.resume()
+	if position.resumed {
+		t.Errorf("resuming before any line was sent should reopen the stream from the start")
+	}
+	later := time.Date(2024, 5, 1, 12, 30, 1, 0, time.UTC)
+	if !position.next(later) || !position.next(later.Add(-time.Second)) {
+		t.Errorf("lines of a stream that was never reopened must all be sent")
+	}
+}
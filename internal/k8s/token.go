@@ -0,0 +1,152 @@
+package k8s
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// tokenRefreshMargin is how long before its expiry a cached token is refreshed
+const tokenRefreshMargin = time.Minute
+
+// tokenFetcher returns a new bearer token along with when it expires
+type tokenFetcher func() (token string, expiry time.Time, err error)
+
+// credentialsError is a failure to get a token for a cluster, e.g. because
+// the IAM role of the cluster cannot be assumed
+type credentialsError struct {
+	cluster string
+	err     error
+}
+
+func (e *credentialsError) Error() string {
+	return fmt.Sprintf("failed to get token for cluster %s: %v", e.cluster, e.err)
+}
+
+func (e *credentialsError) Unwrap() error {
+	return e.err
+}
+
+// isCredentialsError tells whether err comes from failing to get a token
+// rather than from the cluster, failing to reach the token issuer aside
+func isCredentialsError(err error) bool {
+	var credentialsErr *credentialsError
+	if !errors.As(err, &credentialsErr) {
+		return false
+	}
+	var netErr net.Error
+	return !errors.As(credentialsErr.err, &netErr)
+}
+
+// tokenCache caches a bearer token until shortly before it expires
+type tokenCache struct {
+	name  string
+	fetch tokenFetcher
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+func newTokenCache(name string, fetch tokenFetcher) *tokenCache {
+	return &tokenCache{name: name, fetch: fetch}
+}
+
+// Token returns the cached token, fetching a new one when it is about to expire
+func (t *tokenCache) Token() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.token != "" && time.Now().Add(tokenRefreshMargin).Before(t.expiry) {
+		return t.token, nil
+	}
+
+	token, expiry, err := t.fetch()
+	if err != nil {
+		return "", &credentialsError{cluster: t.name, err: err}
+	}
+	fmt.Printf("[tokenCache] Refreshed token for cluster %s, expires at %s\n", t.name, expiry.Format(time.RFC3339))
+
+	t.token = token
+	t.expiry = expiry
+	return token, nil
+}
+
+// Invalidate drops a token the API server rejected so the next call to
+// Token fetches a new one. Tokens other than the rejected one are kept, a
+// concurrent request may have refreshed it already.
+func (t *tokenCache) Invalidate(rejected string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.token == rejected {
+		t.token = ""
+	}
+}
+
+// WrapTransport returns a rest.Config.WrapTransport function that
+// authenticates requests with the cached token
+func (t *tokenCache) WrapTransport(base http.RoundTripper) http.RoundTripper {
+	return &tokenRoundTripper{base: base, tokens: t}
+}
+
+// tokenRoundTripper sets the bearer token on every request and retries a
+// request once with a fresh token when the API server answers 401
+type tokenRoundTripper struct {
+	base   http.RoundTripper
+	tokens *tokenCache
+}
+
+func (rt *tokenRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := rt.tokens.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := rt.base.RoundTrip(withBearerToken(req, token))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	// The token expired early or was revoked. A request body that cannot be
+	// read again rules out a retry.
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil
+	}
+
+	rt.tokens.Invalidate(token)
+	fresh, err := rt.tokens.Token()
+	if err != nil {
+		fmt.Printf("[tokenRoundTripper] Failed to refresh token after 401: %v\n", err)
+		return resp, nil
+	}
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		retry.Body = body
+	}
+
+	// The first response is discarded in favour of the retried one
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if closeErr := resp.Body.Close(); closeErr != nil {
+		fmt.Printf("Warning: failed to close response body: %v\n", closeErr)
+	}
+
+	return rt.base.RoundTrip(withBearerToken(retry, fresh))
+}
+
+// withBearerToken returns a copy of a request authenticated with a token,
+// round trippers must not modify the request they are given
+func withBearerToken(req *http.Request, token string) *http.Request {
+	authenticated := req.Clone(req.Context())
+	authenticated.Header.Set("Authorization", "Bearer "+token)
+	return authenticated
+}
@@ -0,0 +1,179 @@
+package k8s
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// roundTripFunc is a fake base RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// sequentialTokens returns a tokenFetcher handing out token-1, token-2, ...
+// valid for an hour, and the number of tokens fetched so far
+func sequentialTokens() (tokenFetcher, *int) {
+	fetched := 0
+	return func() (string, time.Time, error) {
+		fetched++
+		return fmt.Sprintf("token-%d", fetched), time.Now().Add(time.Hour), nil
+	}, &fetched
+}
+
+// recordedRequest is a request seen by the fake base RoundTripper
+type recordedRequest struct {
+	authorization string
+	body          string
+}
+
+// fakeAPIServer answers the given status codes in order, recording the
+// requests it receives
+func fakeAPIServer(t *testing.T, statuses ...int) (http.RoundTripper, *[]recordedRequest) {
+	var requests []recordedRequest
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if len(requests) == len(statuses) {
+			t.Fatalf("unexpected request %d", len(requests)+1)
+		}
+		recorded := recordedRequest{authorization: req.Header.Get("Authorization")}
+		if req.Body != nil {
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				t.Fatalf("failed to read request body: %v", err)
+			}
+			recorded.body = string(body)
+		}
+		status := statuses[len(requests)]
+		requests = append(requests, recorded)
+		return &http.Response{
+			StatusCode: status,
+			Body:       io.NopCloser(strings.NewReader(http.StatusText(status))),
+			Request:    req,
+		}, nil
+	}), &requests
+}
+
+func TestTokenRoundTripperRetriesOnceOnUnauthorized(t *testing.T) {
+	fetch, fetched := sequentialTokens()
+	base, requests := fakeAPIServer(t, http.StatusUnauthorized, http.StatusOK)
+	rt := newTokenCache("test", fetch).WrapTransport(base)
+
+	req, err := http.NewRequest(http.MethodPost, "https://cluster.example/api/v1/namespaces", bytes.NewReader([]byte(`{"kind":"Namespace"}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	want := []recordedRequest{
+		{authorization: "Bearer token-1", body: `{"kind":"Namespace"}`},
+		{authorization: "Bearer token-2", body: `{"kind":"Namespace"}`},
+	}
+	if len(*requests) != len(want) {
+		t.Fatalf("got %d requests, want %d", len(*requests), len(want))
+	}
+	for i := range want {
+		if (*requests)[i] != want[i] {
+			t.Errorf("request %d = %+v, want %+v", i+1, (*requests)[i], want[i])
+		}
+	}
+	if *fetched != 2 {
+		t.Errorf("fetched %d tokens, want 2", *fetched)
+	}
+	if req.Header.Get("Authorization") != "" {
+		t.Errorf("the original request was modified")
+	}
+}
+
+func TestTokenRoundTripperGivesUpAfterOneRetry(t *testing.T) {
+	fetch, _ := sequentialTokens()
+	base, requests := fakeAPIServer(t, http.StatusUnauthorized, http.StatusUnauthorized)
+	rt := newTokenCache("test", fetch).WrapTransport(base)
+
+	req, err := http.NewRequest(http.MethodGet, "https://cluster.example/version", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+	if len(*requests) != 2 {
+		t.Errorf("got %d requests, want 2", len(*requests))
+	}
+}
+
+func TestTokenRoundTripperDoesNotRetryUnreplayableBody(t *testing.T) {
+	fetch, _ := sequentialTokens()
+	base, requests := fakeAPIServer(t, http.StatusUnauthorized)
+	rt := newTokenCache("test", fetch).WrapTransport(base)
+
+	req, err := http.NewRequest(http.MethodPost, "https://cluster.example/api/v1/namespaces", io.NopCloser(strings.NewReader("body")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.GetBody != nil {
+		t.Fatal("expected a request body that cannot be replayed")
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+	if len(*requests) != 1 {
+		t.Errorf("got %d requests, want 1", len(*requests))
+	}
+}
+
+func TestTokenCacheRefreshesBeforeExpiry(t *testing.T) {
+	expiry := time.Now().Add(tokenRefreshMargin / 2)
+	fetched := 0
+	tokens := newTokenCache("test", func() (string, time.Time, error) {
+		fetched++
+		return fmt.Sprintf("token-%d", fetched), expiry, nil
+	})
+
+	for i := 1; i <= 2; i++ {
+		token, err := tokens.Token()
+		if err != nil {
+			t.Fatalf("Token failed: %v", err)
+		}
+		// A token expiring within the margin is never reused
+		if want := fmt.Sprintf("token-%d", i); token != want {
+			t.Errorf("token = %q, want %q", token, want)
+		}
+	}
+
+	expiry = time.Now().Add(time.Hour)
+	first, _ := tokens.Token()
+	second, _ := tokens.Token()
+	if first != second {
+		t.Errorf("valid token was not reused: %q, %q", first, second)
+	}
+}
+
+func TestTokenCacheReportsFetchErrors(t *testing.T) {
+	tokens := newTokenCache("test", func() (string, time.Time, error) {
+		return "", time.Time{}, errors.New("no credentials")
+	})
+	if _, err := tokens.Token(); err == nil || !strings.Contains(err.Error(), "no credentials") {
+		t.Errorf("err = %v, want the fetch error", err)
+	}
+}
@@ -0,0 +1,612 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
+	watchtools "k8s.io/client-go/tools/watch"
+)
+
+// Job watch event types
+const (
+	// WatchEventJob reports a change of a job condition
+	WatchEventJob = "job"
+	// WatchEventPod reports a pod or one of its containers changing state
+	WatchEventPod = "pod"
+	// WatchEventKubernetes forwards a Kubernetes Event for the job or its pods
+	WatchEventKubernetes = "event"
+	// WatchEventEnd is sent once the job has completed or failed
+	WatchEventEnd = "end"
+	// WatchEventError reports a failure to watch the job
+	WatchEventError = "error"
+)
+
+// Pod states reported in PodState events
+const (
+	PodStateScheduled     = "Scheduled"
+	PodStateUnschedulable = "Unschedulable"
+	PodStatePulling       = "Pulling"
+	PodStateWaiting       = "Waiting"
+	PodStateRunning       = "Running"
+	PodStateTerminated    = "Terminated"
+	PodStateDeleted       = "Deleted"
+)
+
+// JobWatchEvent is one event of WatchJobEvents. Exactly one of Job, Pod and
+// Event is set, depending on Type.
+type JobWatchEvent struct {
+	// ID is the position in the watch after this event, WatchJobEvents
+	// resumes after it when given as resumeFrom
+	ID string `json:"-"`
+
+	Type    string           `json:"type"`
+	Time    time.Time        `json:"time"`
+	Job     *JobCondition    `json:"job,omitempty"`
+	Pod     *PodState        `json:"pod,omitempty"`
+	Event   *KubernetesEvent `json:"event,omitempty"`
+	Message string           `json:"message,omitempty"`
+}
+
+// JobCondition is a condition of a job along with its pod counts
+type JobCondition struct {
+	Name      string `json:"name"`
+	Condition string `json:"condition"`
+	Status    string `json:"status"`
+	Reason    string `json:"reason,omitempty"`
+	Message   string `json:"message,omitempty"`
+	Active    int32  `json:"active"`
+	Succeeded int32  `json:"succeeded"`
+	Failed    int32  `json:"failed"`
+}
+
+// PodState is the state of a job pod, or of one of its containers when
+// Container is set
+type PodState struct {
+	Name      string `json:"name"`
+	Container string `json:"container,omitempty"`
+	Init      bool   `json:"init,omitempty"`
+	Phase     string `json:"phase"`
+	State     string `json:"state"`
+	Reason    string `json:"reason,omitempty"`
+	Message   string `json:"message,omitempty"`
+	ExitCode  *int32 `json:"exitCode,omitempty"`
+	Restarts  int32  `json:"restarts,omitempty"`
+}
+
+// KubernetesEvent is a Kubernetes Event involving the job or one of its pods
+type KubernetesEvent struct {
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+	Count   int32  `json:"count,omitempty"`
+
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// Resources watched by WatchJobEvents, in the order of their resource
+// versions in a JobWatchEvent ID
+const (
+	watchedJob = iota
+	watchedPods
+	watchedEvents
+	watchedResources
+)
+
+// watchRetryInterval is how long WatchJobEvents waits before listing a
+// resource again after a failed list
+const watchRetryInterval = 2 * time.Second
+
+// WatchJobEvents watches a job, its pods and the Kubernetes Events involving
+// them. The watches are resumed from the last resource version when the API
+// server closes them, and the objects are listed again when that version has
+// expired. resumeFrom is the ID of the last event a client received, or ""
+// to start with the current state of the job.
+//
+// The channel is closed once the job reaches its Complete or Failed
+// condition, after a WatchEventEnd event, or when ctx is cancelled.
+func (c *Client) WatchJobEvents(ctx context.Context, namespace, jobName, resumeFrom string) (<-chan JobWatchEvent, error) {
+	job, err := c.GetJob(namespace, jobName)
+	if err != nil {
+		return nil, err
+	}
+	selector, err := metav1.LabelSelectorAsSelector(job.Spec.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector on job %s: %w", job.Name, err)
+	}
+
+	jobs := c.clientset.BatchV1().Jobs(namespace)
+	pods := c.clientset.CoreV1().Pods(namespace)
+	kubeEvents := c.clientset.CoreV1().Events(namespace)
+	jobSelector := fields.OneTermEqualSelector("metadata.name", jobName).String()
+
+	// Events cannot be selected by several involved objects, so the namespace's
+	// events are filtered by handleEvent
+	listWatches := [watchedResources]*cache.ListWatch{
+		watchedJob: {
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				options.FieldSelector = jobSelector
+				return jobs.List(ctx, options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				options.FieldSelector = jobSelector
+				return jobs.Watch(ctx, options)
+			},
+		},
+		watchedPods: {
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				options.LabelSelector = selector.String()
+				return pods.List(ctx, options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				options.LabelSelector = selector.String()
+				return pods.Watch(ctx, options)
+			},
+		},
+		watchedEvents: {
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return kubeEvents.List(ctx, options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return kubeEvents.Watch(ctx, options)
+			},
+		},
+	}
+
+	events := make(chan JobWatchEvent, 100)
+	w := &jobWatch{
+		jobName:     jobName,
+		events:      events,
+		ctx:         ctx,
+		versions:    parseWatchID(resumeFrom),
+		conditions:  map[string]string{},
+		podStates:   map[string]string{},
+		pulling:     map[string]bool{},
+		pods:        pods,
+		podSelector: selector,
+		jobPods:     map[string]bool{},
+	}
+
+	go func() {
+		defer close(events)
+
+		// A client resuming after the job finished missed the end of the watch
+		if finished, _ := JobFinished(job); finished && resumeFrom != "" {
+			w.handleJob(watch.Event{Type: watch.Added, Object: job})
+			return
+		}
+
+		updates := make(chan resourceEvent)
+		for resource, lw := range listWatches {
+			go resumableWatch(ctx, resource, lw, w.versions[resource], updates)
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case update := <-updates:
+				if update.version != "" {
+					w.versions[update.resource] = update.version
+				}
+				if update.err != nil {
+					w.send(JobWatchEvent{Type: WatchEventError, Message: update.err.Error()})
+					continue
+				}
+				if update.event.Object == nil {
+					continue
+				}
+
+				switch update.resource {
+				case watchedJob:
+					if done := w.handleJob(update.event); done {
+						return
+					}
+				case watchedPods:
+					w.handlePod(update.event)
+				case watchedEvents:
+					w.handleEvent(update.event)
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// resourceEvent is an event of one of the resources watched by WatchJobEvents
+type resourceEvent struct {
+	resource int
+	event    watch.Event
+	// version is the resource version to resume from after this event, or ""
+	// while the objects of a list are sent
+	version string
+	err     error
+}
+
+// resumableWatch sends the events of a resource to out until ctx is cancelled.
+// When version is empty the current objects are listed first and sent as
+// Added events. The watch is restarted from the last resource version when
+// the API server closes it, and the objects are listed again once that
+// version has expired (410 Gone).
+func resumableWatch(ctx context.Context, resource int, lw *cache.ListWatch, version string, out chan<- resourceEvent) {
+	send := func(update resourceEvent) bool {
+		update.resource = resource
+		select {
+		case out <- update:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for ctx.Err() == nil {
+		if version == "" {
+			list, err := lw.List(metav1.ListOptions{})
+			if err == nil {
+				version, err = listObjects(list, send)
+			}
+			if err != nil {
+				if ctx.Err() != nil || !send(resourceEvent{err: fmt.Errorf("failed to list: %w", err)}) {
+					return
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(watchRetryInterval):
+				}
+				continue
+			}
+		}
+
+		// The retry watcher resumes from the last resource version it has seen
+		// whenever the API server closes the watch
+		watcher, err := watchtools.NewRetryWatcher(version, lw)
+		if err != nil {
+			version = ""
+			continue
+		}
+
+		// Stop the watcher once the caller is gone, it would otherwise retry
+		// its cancelled watch forever without producing an event. Stop must
+		// only be called once, so this goroutine owns it.
+		finished := make(chan struct{})
+		go func() {
+			select {
+			case <-ctx.Done():
+			case <-finished:
+			}
+			watcher.Stop()
+		}()
+
+		for event := range watcher.ResultChan() {
+			if event.Type == watch.Error {
+				err := apierrors.FromObject(event.Object)
+				if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+					// List again to catch up on what happened since version
+					version = ""
+					break
+				}
+				send(resourceEvent{err: err})
+				continue
+			}
+
+			if accessor, err := meta.Accessor(event.Object); err == nil {
+				version = accessor.GetResourceVersion()
+			}
+			if !send(resourceEvent{event: event, version: version}) {
+				close(finished)
+				return
+			}
+		}
+		close(finished)
+	}
+}
+
+// listObjects sends the objects of a list as Added events and returns the
+// resource version of the list
+func listObjects(list runtime.Object, send func(resourceEvent) bool) (string, error) {
+	listAccessor, err := meta.ListAccessor(list)
+	if err != nil {
+		return "", err
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return "", err
+	}
+
+	version := listAccessor.GetResourceVersion()
+	for i, item := range items {
+		update := resourceEvent{event: watch.Event{Type: watch.Added, Object: item}}
+		// Only resume after the list once all of its objects have been sent
+		if i == len(items)-1 {
+			update.version = version
+		}
+		if !send(update) {
+			return version, nil
+		}
+	}
+	if len(items) == 0 {
+		send(resourceEvent{version: version})
+	}
+	return version, nil
+}
+
+// watchID encodes the resource versions of the watched resources as the ID
+// of a JobWatchEvent
+func watchID(versions [watchedResources]string) string {
+	return strings.Join(versions[:], ".")
+}
+
+// parseWatchID decodes a JobWatchEvent ID, an invalid ID starts a new watch
+func parseWatchID(id string) [watchedResources]string {
+	var versions [watchedResources]string
+	parts := strings.Split(id, ".")
+	if len(parts) == watchedResources {
+		copy(versions[:], parts)
+	}
+	return versions
+}
+
+// jobWatch holds the state of WatchJobEvents used to only report changes
+type jobWatch struct {
+	jobName string
+	events  chan<- JobWatchEvent
+	ctx     context.Context
+
+	// versions holds the resource version reached per watched resource
+	versions [watchedResources]string
+
+	// conditions holds the last reported status per job condition type
+	conditions map[string]string
+	// podStates holds the last reported state per pod and container
+	podStates map[string]string
+	// pulling holds the pods and containers an image pull was reported for
+	pulling map[string]bool
+
+	pods        typedcorev1.PodInterface
+	podSelector labels.Selector
+	// jobPods tells per pod name whether the pod belongs to the job
+	jobPods map[string]bool
+}
+
+func (w *jobWatch) send(event JobWatchEvent) bool {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	event.ID = watchID(w.versions)
+	select {
+	case w.events <- event:
+		return true
+	case <-w.ctx.Done():
+		return false
+	}
+}
+
+// handleJob reports changed job conditions and returns true once the job has
+// completed or failed
+func (w *jobWatch) handleJob(event watch.Event) bool {
+	if event.Type == watch.Error {
+		w.send(JobWatchEvent{Type: WatchEventError, Message: watchErrorMessage(event)})
+		return true
+	}
+	job, ok := event.Object.(*batchv1.Job)
+	if !ok {
+		return false
+	}
+	if event.Type == watch.Deleted {
+		w.send(JobWatchEvent{Type: WatchEventEnd, Message: fmt.Sprintf("Job %s was deleted", job.Name)})
+		return true
+	}
+
+	for _, condition := range job.Status.Conditions {
+		conditionType := string(condition.Type)
+		if w.conditions[conditionType] == string(condition.Status) {
+			continue
+		}
+		w.conditions[conditionType] = string(condition.Status)
+
+		w.send(JobWatchEvent{
+			Type: WatchEventJob,
+			Time: condition.LastTransitionTime.Time,
+			Job: &JobCondition{
+				Name:      job.Name,
+				Condition: conditionType,
+				Status:    string(condition.Status),
+				Reason:    condition.Reason,
+				Message:   condition.Message,
+				Active:    job.Status.Active,
+				Succeeded: job.Status.Succeeded,
+				Failed:    job.Status.Failed,
+			},
+		})
+	}
+
+	// Failed pods are retried until the backoff limit is reached, only the
+	// job conditions are final
+	if finished, condition := JobFinished(job); finished {
+		w.send(JobWatchEvent{Type: WatchEventEnd, Message: fmt.Sprintf("Job %s: %s", job.Name, condition)})
+		return true
+	}
+	return false
+}
+
+// handlePod reports scheduling and container state changes of a job pod
+func (w *jobWatch) handlePod(event watch.Event) {
+	pod, ok := event.Object.(*corev1.Pod)
+	if !ok {
+		return
+	}
+	// Deleted pods are remembered too, their events may still arrive
+	w.jobPods[pod.Name] = true
+	if event.Type == watch.Deleted {
+		w.report(PodState{Name: pod.Name, Phase: string(pod.Status.Phase), State: PodStateDeleted})
+		return
+	}
+
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type != corev1.PodScheduled {
+			continue
+		}
+		state := PodState{Name: pod.Name, Phase: string(pod.Status.Phase)}
+		if condition.Status == corev1.ConditionTrue {
+			state.State = PodStateScheduled
+		} else {
+			state.State = PodStateUnschedulable
+			state.Reason = condition.Reason
+			state.Message = condition.Message
+		}
+		w.report(state)
+	}
+
+	for _, status := range pod.Status.InitContainerStatuses {
+		w.report(containerState(pod, status, true))
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		w.report(containerState(pod, status, false))
+	}
+}
+
+// report sends a pod state unless it is the last one reported for the pod or
+// container
+func (w *jobWatch) report(state PodState) {
+	key := state.Name + "/" + state.Container
+	value := fmt.Sprintf("%s/%s/%d", state.State, state.Reason, state.Restarts)
+	if w.podStates[key] == value {
+		return
+	}
+	w.podStates[key] = value
+	w.send(JobWatchEvent{Type: WatchEventPod, Pod: &state})
+}
+
+// containerState converts a container status into a PodState
+func containerState(pod *corev1.Pod, status corev1.ContainerStatus, init bool) PodState {
+	state := PodState{
+		Name:      pod.Name,
+		Container: status.Name,
+		Init:      init,
+		Phase:     string(pod.Status.Phase),
+		Restarts:  status.RestartCount,
+	}
+
+	switch {
+	case status.State.Terminated != nil:
+		terminated := status.State.Terminated
+		exitCode := terminated.ExitCode
+		state.State = PodStateTerminated
+		state.Reason = terminated.Reason
+		state.Message = terminated.Message
+		state.ExitCode = &exitCode
+	case status.State.Running != nil:
+		state.State = PodStateRunning
+	case status.State.Waiting != nil:
+		state.State = PodStateWaiting
+		state.Reason = status.State.Waiting.Reason
+		state.Message = status.State.Waiting.Message
+	}
+	return state
+}
+
+// handleEvent forwards Kubernetes Events involving the job or its pods
+func (w *jobWatch) handleEvent(event watch.Event) {
+	if event.Type == watch.Deleted {
+		return
+	}
+	kubeEvent, ok := event.Object.(*corev1.Event)
+	if !ok {
+		return
+	}
+
+	involved := kubeEvent.InvolvedObject
+	switch {
+	case involved.Kind == "Job" && involved.Name == w.jobName:
+	case involved.Kind == "Pod" && w.isJobPod(involved.Name):
+	default:
+		return
+	}
+
+	converted := newKubernetesEvent(kubeEvent)
+	eventTime := converted.LastSeen
+
+	w.send(JobWatchEvent{
+		Type:  WatchEventKubernetes,
+		Time:  eventTime,
+		Event: &converted,
+	})
+
+	// Image pulls are only visible through events
+	if involved.Kind == "Pod" && kubeEvent.Reason == "Pulling" {
+		key := involved.Name + "/" + involved.FieldPath
+		if !w.pulling[key] {
+			w.pulling[key] = true
+			w.send(JobWatchEvent{
+				Type: WatchEventPod,
+				Time: eventTime,
+				Pod: &PodState{
+					Name:      involved.Name,
+					Container: fieldPathContainer(involved.FieldPath),
+					State:     PodStatePulling,
+					Message:   kubeEvent.Message,
+				},
+			})
+		}
+	}
+}
+
+// isJobPod tells whether a pod belongs to the job. Pods of other jobs can
+// share the job name as prefix, e.g. reruns or a job named "<job>-db", so
+// pods not seen by the pods watch yet are checked against the job selector.
+func (w *jobWatch) isJobPod(name string) bool {
+	if !strings.HasPrefix(name, w.jobName+"-") {
+		return false
+	}
+	if belongs, ok := w.jobPods[name]; ok {
+		return belongs
+	}
+
+	pod, err := w.pods.Get(w.ctx, name, metav1.GetOptions{})
+	if err != nil {
+		// Retry on the next event unless the pod is gone
+		if apierrors.IsNotFound(err) {
+			w.jobPods[name] = false
+		}
+		return false
+	}
+	w.jobPods[name] = w.podSelector.Matches(labels.Set(pod.Labels))
+	return w.jobPods[name]
+}
+
+// fieldPathContainer returns the container name of an involved object field
+// path such as "spec.containers{app}"
+func fieldPathContainer(fieldPath string) string {
+	start := strings.Index(fieldPath, "{")
+	end := strings.LastIndex(fieldPath, "}")
+	if start < 0 || end <= start {
+		return ""
+	}
+	return fieldPath[start+1 : end]
+}
+
+// watchErrorMessage extracts the message of a watch.Error event
+func watchErrorMessage(event watch.Event) string {
+	if status, ok := event.Object.(*metav1.Status); ok {
+		return status.Message
+	}
+	return "watch error"
+}
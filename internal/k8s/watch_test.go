@@ -0,0 +1,36 @@
+package k8s
+
+import "testing"
+
+func TestParseWatchID(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		want [watchedResources]string
+	}{
+		{"every resource", "10.20.30", [watchedResources]string{"10", "20", "30"}},
+		{"resource not reached yet", "10..30", [watchedResources]string{"10", "", "30"}},
+		{"empty", "", [watchedResources]string{}},
+		{"too few parts", "10.20", [watchedResources]string{}},
+		{"too many parts", "10.20.30.40", [watchedResources]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseWatchID(tt.id); got != tt.want {
+				t.Errorf("parseWatchID(%q) = %q, want %q", tt.id, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWatchIDRoundTrip(t *testing.T) {
+	for _, versions := range [][watchedResources]string{
+		{"10", "20", "30"},
+		{"10", "", ""},
+		{},
+	} {
+		if got := parseWatchID(watchID(versions)); got != versions {
+			t.Errorf("parseWatchID(watchID(%q)) = %q", versions, got)
+		}
+	}
+}
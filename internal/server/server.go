@@ -23,7 +23,7 @@ func (s *Server) Run(addr string) error {
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, "+handlers.ClusterHeader)
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -40,23 +40,37 @@ func (s *Server) Run(addr string) error {
 	// Web routes
 	r.GET("/", s.handlers.Index)
 
+	// The versioned API, /api keeps the response shapes existing clients parse
+	s.registerAPI(r.Group("/api/v1"))
+	s.registerAPI(r.Group("/api", handlers.LegacyAPI))
+
+	return r.Run(addr)
+}
+
+// registerAPI registers the API routes on a route group
+func (s *Server) registerAPI(api *gin.RouterGroup) {
 	// Cluster management
-	r.GET("/api/clusters", s.handlers.GetClusters)
-	r.POST("/api/clusters/switch", s.handlers.SwitchCluster) // Must be before :name routes
-	r.POST("/api/clusters", s.handlers.AddCluster)
-	r.GET("/api/clusters/:name", s.handlers.GetClusterInfo)
-	r.DELETE("/api/clusters/:name", s.handlers.DeleteCluster)
+	api.GET("/clusters", s.handlers.GetClusters)
+	api.POST("/clusters/switch", s.handlers.SwitchCluster) // Must be before :name routes
+	api.POST("/clusters", s.handlers.AddCluster)
+	api.GET("/clusters/:name", s.handlers.GetClusterInfo)
+	api.GET("/clusters/:name/health", s.handlers.GetClusterHealth)
+	api.DELETE("/clusters/:name", s.handlers.DeleteCluster)
 
 	// Kubernetes resources
-	r.GET("/api/namespaces", s.handlers.GetNamespaces)
-	r.GET("/api/deployments", s.handlers.GetDeployments)
-	r.GET("/api/deployments/:namespace/:name", s.handlers.GetDeployment)
-	r.GET("/api/jobs", s.handlers.GetAllJobs)
-	r.POST("/api/jobs", s.handlers.CreateJob)
-	r.GET("/api/jobs/:namespace/:name", s.handlers.GetJob)
-	r.DELETE("/api/jobs/:namespace/:name", s.handlers.DeleteJob)
-	r.GET("/api/jobs/:namespace/:name/logs", s.handlers.GetJobLogs)
-	r.GET("/api/jobs/:namespace/:name/watch", s.handlers.WatchJob)
-
-	return r.Run(addr)
+	api.GET("/namespaces", s.handlers.GetNamespaces)
+	api.GET("/deployments", s.handlers.GetDeployments)
+	api.GET("/deployments/:namespace/:name", s.handlers.GetDeployment)
+	api.GET("/job-defaults", s.handlers.GetJobDefaults)
+	api.GET("/capabilities", s.handlers.GetCapabilities)
+	api.GET("/jobs", s.handlers.GetAllJobs)
+	api.POST("/jobs", s.handlers.CreateJob)
+	api.GET("/jobs/stream", s.handlers.StreamJobs)
+	api.GET("/jobs/:namespace/:name", s.handlers.GetJob)
+	api.DELETE("/jobs/:namespace/:name", s.handlers.DeleteJob)
+	api.POST("/jobs/:namespace/:name/rerun", s.handlers.RerunJob)
+	api.GET("/jobs/:namespace/:name/events", s.handlers.GetJobEvents)
+	api.GET("/jobs/:namespace/:name/logs", s.handlers.GetJobLogs)
+	api.GET("/jobs/:namespace/:name/logs/stream", s.handlers.StreamJobLogs)
+	api.GET("/jobs/:namespace/:name/watch", s.handlers.WatchJob)
 }
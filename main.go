@@ -16,8 +16,19 @@ func main() {
 		log.Fatalf("Failed to create Kubernetes client: %v", err)
 	}
 
-	// Create handlers
-	h := handlers.New(k8sClient)
+	// Load server-side defaults for spawned jobs
+	jobDefaults, err := handlers.JobDefaultsFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid job defaults: %v", err)
+	}
+
+	trustUserHeaders, err := handlers.TrustUserHeadersFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	// Create handlers with a client pool seeded with the local cluster
+	h := handlers.New(k8s.NewClientPool(k8sClient), jobDefaults, trustUserHeaders)
 
 	// Create server
 	srv := server.New(h)
@@ -4,17 +4,22 @@ class SpawnrApp {
         this.currentProfile = '';
         this.currentNamespace = '';
         this.currentDeployment = '';
+        this.deployments = new Map();
         this.jobs = new Map();
         this.clusterStatuses = new Map();
+        // Actions spawnr may perform, keyed by cluster/namespace
+        this.capabilities = new Map();
         this.init();
     }
 
     async init() {
         this.initTheme();
+        await this.loadJobDefaults();
         await this.loadClusters();
         this.setupEventListeners();
-        // Load existing jobs on page load
+        // Load existing jobs on page load and keep them up to date
         await this.loadAllJobs();
+        this.startJobsStream();
     }
 
     initTheme() {
@@ -54,6 +59,7 @@ class SpawnrApp {
 
         document.getElementById('deploymentSelect').addEventListener('change', (e) => {
             this.currentDeployment = e.target.value;
+            this.loadContainers();
             this.updateCreateJobButton();
         });
 
@@ -65,10 +71,27 @@ class SpawnrApp {
             this.addCluster();
         });
 
+        document.getElementById('clusterType').addEventListener('change', () => {
+            this.updateClusterTypeFields();
+        });
+        this.updateClusterTypeFields();
+
         document.getElementById('refreshJobsBtn').addEventListener('click', () => {
             this.refreshJobs();
         });
 
+        // Log filters reload the logs of the job shown in the logs modal
+        ['logContainerSelect', 'logPrevious', 'logTimestamps', 'logFollow'].forEach(id => {
+            document.getElementById(id).addEventListener('change', () => {
+                this.loadJobLogs();
+            });
+        });
+
+        // Stop following logs once the logs modal is closed
+        document.getElementById('logsModal').addEventListener('hidden.bs.modal', () => {
+            this.stopLogStream();
+        });
+
         // Theme toggle
         const themeToggle = document.getElementById('themeToggle');
         if (themeToggle) {
@@ -98,7 +121,8 @@ class SpawnrApp {
 
     async loadClusters() {
         try {
-            const response = await fetch('/api/clusters');
+            // The dropdown doesn't show health, skip the probes
+            const response = await fetch('/api/v1/clusters?health=false');
             const clusters = await response.json();
             
             const select = document.getElementById('clusterSelect');
@@ -127,7 +151,24 @@ class SpawnrApp {
             }
         } catch (error) {
             console.error('Failed to load clusters:', error);
-            this.showAlert('Failed to load clusters. Make sure AWS CLI is configured and you have EKS clusters in your account.', 'danger');
+            this.showAlert('Failed to load clusters. Make sure AWS credentials are configured and you have EKS clusters in your account.', 'danger');
+        }
+    }
+
+    async loadJobDefaults() {
+        try {
+            const response = await fetch('/api/v1/job-defaults');
+            if (!response.ok) {
+                return;
+            }
+            const defaults = await response.json();
+            // Show the server defaults as placeholders for the job settings
+            ['backoffLimit', 'activeDeadlineSeconds', 'ttlSecondsAfterFinished'].forEach(field => {
+                const input = document.getElementById(field);
+                input.placeholder = defaults[field] !== undefined ? `Default: ${defaults[field]}` : 'Cluster default';
+            });
+        } catch (error) {
+            console.error('Failed to load job defaults:', error);
         }
     }
 
@@ -139,7 +180,7 @@ class SpawnrApp {
         }
 
         try {
-            const response = await fetch('/api/clusters/switch', {
+            const response = await fetch('/api/v1/clusters/switch', {
                 method: 'POST',
                 headers: {
                     'Content-Type': 'application/json'
@@ -151,6 +192,8 @@ class SpawnrApp {
 
             if (response.ok) {
                 await this.loadNamespaces();
+                await this.loadAllJobs();
+                this.startJobsStream();
                 if (showNotification) {
                     this.showAlert(`Switched to cluster: ${this.currentCluster}`, 'success');
                 }
@@ -164,6 +207,16 @@ class SpawnrApp {
         }
     }
 
+    // apiFetch calls the API for the given cluster, defaulting to the selected one.
+    // The cluster is sent with every request so other users' tabs are unaffected.
+    apiFetch(url, options = {}, cluster = this.currentCluster) {
+        const headers = { ...(options.headers || {}) };
+        if (cluster) {
+            headers['X-Spawnr-Cluster'] = cluster;
+        }
+        return fetch(url, { ...options, headers });
+    }
+
     sanitizeJobName(name) {
         // Convert to lowercase
         name = name.toLowerCase();
@@ -193,16 +246,18 @@ class SpawnrApp {
 
     async loadAllJobs() {
         try {
-            const response = await fetch('/api/jobs');
+            const response = await this.apiFetch('/api/v1/jobs');
             if (response.ok) {
-                const jobs = await response.json();
+                const jobs = (await response.json()).items;
                 const container = document.getElementById('jobsContainer');
                 container.innerHTML = '';
+                this.jobs.clear();
                 
                 // Handle null or empty jobs array
                 if (!jobs || jobs.length === 0) {
                     container.innerHTML = '<p class="text-center text-muted">No jobs created yet</p>';
                 } else {
+                    await this.loadCapabilities(jobs.map(job => job.namespace));
                     jobs.forEach(job => this.addJobCard(job));
                 }
             }
@@ -214,7 +269,7 @@ class SpawnrApp {
 
     async loadNamespaces() {
         try {
-            const response = await fetch('/api/namespaces');
+            const response = await this.apiFetch('/api/v1/namespaces');
             const namespaces = await response.json();
             
             const select = document.getElementById('namespaceSelect');
@@ -223,8 +278,8 @@ class SpawnrApp {
             
             namespaces.forEach(ns => {
                 const option = document.createElement('option');
-                option.value = ns.metadata.name;
-                option.textContent = ns.metadata.name;
+                option.value = ns.name;
+                option.textContent = ns.name;
                 select.appendChild(option);
             });
         } catch (error) {
@@ -240,18 +295,30 @@ class SpawnrApp {
             return;
         }
 
+        await this.loadCapabilities([this.currentNamespace]);
+        this.updateCreateJobButton();
+        const listDeployments = this.capability(this.currentNamespace, 'listDeployments');
+        if (!listDeployments.allowed) {
+            document.getElementById('deploymentSelect').innerHTML = '<option value="">Not allowed to list deployments</option>';
+            document.getElementById('deploymentSelect').disabled = true;
+            this.showAlert(this.escapeHtml(listDeployments.message), 'warning');
+            return;
+        }
+
         try {
-            const response = await fetch(`/api/deployments?namespace=${this.currentNamespace}`);
+            const response = await this.apiFetch(`/api/v1/deployments?namespace=${this.currentNamespace}`);
             const deployments = await response.json();
             
             const select = document.getElementById('deploymentSelect');
             select.innerHTML = '<option value="">Select a deployment</option>';
             select.disabled = false;
+            this.deployments.clear();
             
             deployments.forEach(deployment => {
+                this.deployments.set(deployment.name, deployment);
                 const option = document.createElement('option');
-                option.value = deployment.metadata.name;
-                option.textContent = deployment.metadata.name;
+                option.value = deployment.name;
+                option.textContent = deployment.name;
                 select.appendChild(option);
             });
         } catch (error) {
@@ -260,12 +327,84 @@ class SpawnrApp {
         }
     }
 
+    loadContainers() {
+        const select = document.getElementById('containerSelect');
+        const deployment = this.deployments.get(this.currentDeployment);
+
+        if (!deployment) {
+            select.innerHTML = '<option value="">Select a deployment first</option>';
+            select.disabled = true;
+            return;
+        }
+
+        // The first container is the default target, matching the server
+        select.innerHTML = '';
+        select.disabled = false;
+        deployment.containers.forEach(container => {
+            const option = document.createElement('option');
+            option.value = container.name;
+            option.textContent = container.name;
+            select.appendChild(option);
+        });
+    }
+
     updateCreateJobButton() {
         const jobName = document.getElementById('jobName').value;
         const command = document.getElementById('command').value;
         const createBtn = document.getElementById('createJobBtn');
+        const createJobs = this.capability(this.currentNamespace, 'createJobs');
         
-        createBtn.disabled = !(this.currentNamespace && this.currentDeployment && jobName && command);
+        createBtn.disabled = !(createJobs.allowed && this.currentNamespace && this.currentDeployment && jobName && command);
+        document.getElementById('createJobPermission').textContent = createJobs.allowed ? '' : createJobs.message;
+    }
+
+    // loadCapabilities checks which actions spawnr may perform in the given
+    // namespaces of the selected cluster, skipping namespaces checked already
+    async loadCapabilities(namespaces) {
+        const cluster = this.currentCluster;
+        const unchecked = [...new Set(namespaces)].filter(ns => ns && !this.capabilities.has(`${cluster}/${ns}`));
+        if (unchecked.length === 0) return;
+
+        // Mark the namespaces as checked right away so a failed check is not
+        // retried for every job card, actions stay enabled when unknown
+        unchecked.forEach(ns => this.capabilities.set(`${cluster}/${ns}`, {}));
+
+        // The server checks at most 20 namespaces per request
+        for (let i = 0; i < unchecked.length; i += 20) {
+            const params = new URLSearchParams();
+            unchecked.slice(i, i + 20).forEach(ns => params.append('namespace', ns));
+            try {
+                const response = await this.apiFetch(`/api/v1/capabilities?${params}`, {}, cluster);
+                if (response.ok) {
+                    const data = await response.json();
+                    Object.entries(data.namespaces).forEach(([ns, actions]) => {
+                        this.capabilities.set(`${cluster}/${ns}`, actions);
+                    });
+                } else {
+                    const error = await response.json();
+                    console.warn('Failed to check permissions:', error.error);
+                }
+            } catch (error) {
+                console.warn('Failed to check permissions:', error);
+            }
+        }
+    }
+
+    // capability tells whether spawnr may perform an action in a namespace of
+    // the selected cluster, actions are allowed until proven otherwise
+    capability(namespace, action) {
+        const actions = this.capabilities.get(`${this.currentCluster}/${namespace}`) || {};
+        return actions[action] || { allowed: true };
+    }
+
+    // actionButton disables a job card button whose action spawnr lacks the
+    // permissions for, explaining why on hover
+    actionButton(namespace, action, button) {
+        const capability = this.capability(namespace, action);
+        if (capability.allowed) return button;
+        // Disabled buttons don't show tooltips, their wrapper does
+        const title = this.escapeHtml(capability.message).replace(/"/g, '&quot;');
+        return `<span class="d-inline-block" tabindex="0" title="${title}">${button.replace('<button ', '<button disabled ')}</span>`;
     }
 
     async createJob() {
@@ -284,13 +423,19 @@ class SpawnrApp {
             return;
         }
 
+        const envOverrides = this.parseEnvOverrides(document.getElementById('envOverrides').value);
+        if (envOverrides.error) {
+            this.showAlert(envOverrides.error, 'warning');
+            return;
+        }
+
         const createBtn = document.getElementById('createJobBtn');
         const originalText = createBtn.innerHTML;
         createBtn.innerHTML = '<span class="spinner-border spinner-border-sm" role="status"></span> Creating...';
         createBtn.disabled = true;
 
         try {
-            const response = await fetch('/api/jobs', {
+            const response = await this.apiFetch('/api/v1/jobs', {
                 method: 'POST',
                 headers: {
                     'Content-Type': 'application/json',
@@ -299,14 +444,29 @@ class SpawnrApp {
                     namespace: this.currentNamespace,
                     deployment: this.currentDeployment,
                     jobName: jobName,
-                    command: command
+                    command: command,
+                    container: document.getElementById('containerSelect').value,
+                    dropOtherContainers: document.getElementById('dropOtherContainers').checked,
+                    dropInitContainers: document.getElementById('dropInitContainers').checked,
+                    image: document.getElementById('imageOverride').value.trim(),
+                    env: envOverrides.env,
+                    removeEnv: envOverrides.removeEnv,
+                    resources: this.readResourceOverrides(),
+                    skipSanitize: document.getElementById('skipSanitize').checked,
+                    reason: document.getElementById('jobReason').value.trim(),
+                    nameMode: document.getElementById('uniqueJobName').checked ? 'timestamp' : 'exact',
+                    ...this.readJobSettings()
                 })
             });
 
             if (response.ok) {
-                const job = await response.json();
-                this.showAlert('Job created successfully!', 'success');
-                this.addJobCard(job);
+                const result = await response.json();
+                const overrides = (result.changes || []).filter(change => change.field !== 'command');
+                const summary = overrides.length > 0
+                    ? ` Overrides: ${overrides.map(change => this.describeChange(change)).join(', ')}`
+                    : '';
+                this.showAlert(`Job created successfully!${summary}`, 'success');
+                this.addJobCard(result.job, true);
                 this.clearForm();
             } else {
                 const error = await response.json();
@@ -321,7 +481,130 @@ class SpawnrApp {
         }
     }
 
-    addJobCard(job) {
+    // parseEnvOverrides reads KEY=VALUE lines, a line of -KEY removes the variable
+    parseEnvOverrides(text) {
+        const env = {};
+        const removeEnv = [];
+
+        for (const rawLine of text.split('\n')) {
+            const line = rawLine.trim();
+            if (!line) {
+                continue;
+            }
+            if (line.startsWith('-')) {
+                removeEnv.push(line.substring(1).trim());
+                continue;
+            }
+            const separator = line.indexOf('=');
+            if (separator <= 0) {
+                return { error: `Invalid environment override "${line}", expected KEY=VALUE or -KEY` };
+            }
+            env[line.substring(0, separator).trim()] = line.substring(separator + 1);
+        }
+
+        return { env, removeEnv };
+    }
+
+    // readJobSettings returns the job spec controls that were filled in
+    readJobSettings() {
+        const settings = {};
+        ['backoffLimit', 'activeDeadlineSeconds', 'ttlSecondsAfterFinished', 'completions', 'parallelism'].forEach(field => {
+            const value = document.getElementById(field).value.trim();
+            if (value !== '') {
+                settings[field] = parseInt(value, 10);
+            }
+        });
+
+        const completionMode = document.getElementById('completionMode').value;
+        if (completionMode) {
+            settings.completionMode = completionMode;
+        }
+        return settings;
+    }
+
+    // readResourceOverrides builds the resources override for the selected container
+    readResourceOverrides() {
+        const container = document.getElementById('containerSelect').value;
+        const requests = {};
+        const limits = {};
+
+        ['cpu', 'memory'].forEach(resource => {
+            const request = document.getElementById(`${resource}Request`).value.trim();
+            const limit = document.getElementById(`${resource}Limit`).value.trim();
+            if (request) requests[resource] = request;
+            if (limit) limits[resource] = limit;
+        });
+
+        if (!container || (Object.keys(requests).length === 0 && Object.keys(limits).length === 0)) {
+            return undefined;
+        }
+        return { [container]: { requests, limits } };
+    }
+
+    describeChange(change) {
+        const target = change.container ? `${change.container} ${change.field}` : change.field;
+        if (!change.to) {
+            return `${target} removed`;
+        }
+        return `${target} → ${change.to}`;
+    }
+
+    // startJobsStream applies the jobs added, updated or deleted on the selected
+    // cluster to the jobs list as they happen
+    startJobsStream() {
+        if (this.jobsStream) {
+            this.jobsStream.close();
+        }
+
+        // EventSource cannot set headers, so the cluster goes in the query
+        const params = new URLSearchParams();
+        if (this.currentCluster) {
+            params.set('cluster', this.currentCluster);
+        }
+
+        const source = new EventSource(`/api/v1/jobs/stream?${params}`);
+        this.jobsStream = source;
+
+        let opened = false;
+        source.addEventListener('open', () => {
+            // Changes may have been missed while reconnecting
+            if (opened) {
+                this.loadAllJobs();
+            }
+            opened = true;
+        });
+        source.addEventListener('added', (e) => {
+            this.addJobCard(JSON.parse(e.data), true);
+        });
+        source.addEventListener('updated', (e) => {
+            this.addJobCard(JSON.parse(e.data), true);
+        });
+        source.addEventListener('deleted', (e) => {
+            const job = JSON.parse(e.data);
+            this.removeJobCard(job.namespace, job.name);
+        });
+    }
+
+    jobCardId(namespace, name) {
+        return `job-${namespace}/${name}`;
+    }
+
+    removeJobCard(namespace, name) {
+        this.jobs.delete(`${namespace}/${name}`);
+        const jobCard = document.getElementById(this.jobCardId(namespace, name));
+        if (jobCard) {
+            jobCard.remove();
+        }
+
+        const container = document.getElementById('jobsContainer');
+        if (this.jobs.size === 0 && !container.querySelector('.job-card')) {
+            container.innerHTML = '<p class="text-center text-muted">No jobs created yet</p>';
+        }
+    }
+
+    // addJobCard renders a job, replacing its card when it is shown already.
+    // New cards are appended, or prepended for newly created jobs.
+    addJobCard(job, prepend = false) {
         const container = document.getElementById('jobsContainer');
         
         // Remove "no jobs" message if it exists
@@ -330,23 +613,34 @@ class SpawnrApp {
             noJobsMsg.remove();
         }
 
+        this.jobs.set(`${job.namespace}/${job.name}`, job);
+
         const jobCard = document.createElement('div');
         jobCard.className = 'card job-card';
-        jobCard.id = `job-${job.metadata.name}`;
+        jobCard.id = this.jobCardId(job.namespace, job.name);
         
-        const status = this.getJobStatus(job);
+        const status = job.status;
         const statusClass = this.getStatusClass(status);
+        const source = job.source || {};
+        const sourceDetails = [
+            source.deployment ? `Deployment: ${this.escapeHtml(source.deployment)}` : '',
+            source.requestedBy ? `By: ${this.escapeHtml(source.requestedBy)}` : '',
+            source.reason ? `Reason: ${this.escapeHtml(source.reason)}` : '',
+            source.rerunOf ? `Rerun of: ${this.escapeHtml(source.rerunOf)}` : ''
+        ].filter(Boolean).join(' | ');
         
         jobCard.innerHTML = `
             <div class="card-body">
                 <div class="d-flex justify-content-between align-items-start">
                     <div>
-                        <h6 class="card-title">${job.metadata.name}</h6>
+                        <h6 class="card-title">${job.name}</h6>
                         <p class="card-text">
                             <small class="text-muted">
-                                Namespace: ${job.metadata.namespace} | 
-                                Created: ${new Date(job.metadata.creationTimestamp).toLocaleString()}
+                                Namespace: ${job.namespace} | 
+                                Created: ${new Date(job.createdAt).toLocaleString()}
                             </small>
+                            ${sourceDetails ? `<br><small class="text-muted">${sourceDetails}</small>` : ''}
+                            ${source.command ? `<br><code class="small">${this.escapeHtml(source.command)}</code>` : ''}
                         </p>
                     </div>
                     <div>
@@ -354,24 +648,50 @@ class SpawnrApp {
                     </div>
                 </div>
                 <div class="mt-2">
-                    <button class="btn btn-sm btn-outline-primary me-2" onclick="app.viewJobLogs('${job.metadata.namespace}', '${job.metadata.name}')">
+                    ${this.actionButton(job.namespace, 'viewLogs', `<button class="btn btn-sm btn-outline-primary me-2" onclick="app.viewJobLogs('${job.namespace}', '${job.name}')">
                         <i class="fas fa-file-alt"></i> View Logs
+                    </button>`)}
+                    <button class="btn btn-sm btn-outline-secondary me-2" onclick="app.showJobDetails('${job.namespace}', '${job.name}')">
+                        <i class="fas fa-stethoscope"></i> Details
                     </button>
-                    <button class="btn btn-sm btn-outline-danger" onclick="app.deleteJob('${job.metadata.namespace}', '${job.metadata.name}')">
-                        <i class="fas fa-trash"></i> Delete
+                    ${this.actionButton(job.namespace, 'createJobs', `<button class="btn btn-sm btn-outline-secondary me-2" onclick="app.rerunJob('${job.namespace}', '${job.name}')">
+                        <i class="fas fa-redo"></i> Rerun
+                    </button>`)}
+                    <button class="btn btn-sm btn-outline-secondary me-2" onclick="app.showJobHistory('${job.namespace}', '${job.name}')">
+                        <i class="fas fa-history"></i> History
                     </button>
+                    ${this.actionButton(job.namespace, 'deleteJobs', `<button class="btn btn-sm btn-outline-danger" onclick="app.deleteJob('${job.namespace}', '${job.name}')">
+                        <i class="fas fa-trash"></i> Delete
+                    </button>`)}
                 </div>
             </div>
         `;
         
-        container.appendChild(jobCard);
+        const existing = document.getElementById(jobCard.id);
+        if (existing) {
+            existing.replaceWith(jobCard);
+        } else if (prepend) {
+            container.prepend(jobCard);
+        } else {
+            container.appendChild(jobCard);
+        }
+
+        // Jobs of a namespace not checked yet, e.g. from the jobs stream, are
+        // rendered again once its permissions are known
+        if (!this.capabilities.has(`${this.currentCluster}/${job.namespace}`)) {
+            this.loadCapabilities([job.namespace]).then(() => {
+                const current = this.jobs.get(`${job.namespace}/${job.name}`);
+                if (current && document.getElementById(jobCard.id)) {
+                    this.addJobCard(current);
+                }
+            });
+        }
     }
 
-    getJobStatus(job) {
-        if (job.status.succeeded > 0) return 'Succeeded';
-        if (job.status.failed > 0) return 'Failed';
-        if (job.status.active > 0) return 'Running';
-        return 'Pending';
+    escapeHtml(value) {
+        const div = document.createElement('div');
+        div.textContent = value;
+        return div.innerHTML;
     }
 
     getStatusClass(status) {
@@ -385,21 +705,62 @@ class SpawnrApp {
 
     async viewJobLogs(namespace, name) {
         const modal = new bootstrap.Modal(document.getElementById('logsModal'));
+        this.logsTarget = { namespace, name };
+
+        const select = document.getElementById('logContainerSelect');
+        select.innerHTML = '<option value="">All pods and containers</option>';
+        document.getElementById('logPrevious').checked = false;
+        document.getElementById('logTimestamps').checked = false;
+        document.getElementById('logFollow').checked = false;
+
+        modal.show();
+        await this.loadJobLogs(true);
+    }
+
+    // loadJobLogs fetches the logs for the pod and container selected in the logs modal
+    async loadJobLogs(populateSelect = false) {
+        const { namespace, name } = this.logsTarget;
         const logContent = document.getElementById('logContent');
-        
+        const select = document.getElementById('logContainerSelect');
         logContent.textContent = 'Loading logs...';
-        modal.show();
+        this.stopLogStream();
+
+        const params = new URLSearchParams();
+        if (select.value) {
+            const [pod, container] = select.value.split('/');
+            params.set('pod', pod);
+            params.set('container', container);
+        }
+        if (document.getElementById('logTimestamps').checked) {
+            params.set('timestamps', 'true');
+        }
+        if (document.getElementById('logFollow').checked) {
+            this.followJobLogs(namespace, name, params);
+            return;
+        }
+        if (document.getElementById('logPrevious').checked) {
+            params.set('previous', 'true');
+        }
 
         try {
-            const response = await fetch(`/api/jobs/${namespace}/${name}/logs`);
+            const response = await this.apiFetch(`/api/v1/jobs/${namespace}/${name}/logs?${params}`);
             if (response.ok) {
                 const data = await response.json();
+                if (populateSelect) {
+                    (data.containers || []).forEach(entry => {
+                        const option = document.createElement('option');
+                        option.value = `${entry.pod}/${entry.container}`;
+                        option.textContent = `${entry.pod} / ${entry.container}${entry.init ? ' (init)' : ''}`;
+                        select.appendChild(option);
+                    });
+                }
                 // Preserve line breaks by using a pre element or setting white-space
                 logContent.textContent = data.logs || 'No logs available';
                 // Ensure line breaks are preserved
                 logContent.style.whiteSpace = 'pre-wrap';
             } else {
-                logContent.textContent = 'Failed to load logs';
+                const error = await response.json();
+                logContent.textContent = `Failed to load logs: ${error.error}`;
             }
         } catch (error) {
             console.error('Failed to load logs:', error);
@@ -407,22 +768,235 @@ class SpawnrApp {
         }
     }
 
+    // followJobLogs tails the logs of a job live, moving on to the next pod
+    // when the job retries, until the job finishes
+    followJobLogs(namespace, name, params) {
+        const logContent = document.getElementById('logContent');
+        logContent.textContent = '';
+        logContent.style.whiteSpace = 'pre-wrap';
+
+        // EventSource cannot set headers, so the cluster goes in the query
+        if (this.currentCluster) {
+            params.set('cluster', this.currentCluster);
+        }
+
+        const appendLine = (text) => {
+            const atBottom = logContent.scrollHeight - logContent.scrollTop - logContent.clientHeight < 20;
+            logContent.textContent += text + '\n';
+            if (atBottom) {
+                logContent.scrollTop = logContent.scrollHeight;
+            }
+        };
+
+        const source = new EventSource(`/api/v1/jobs/${namespace}/${name}/logs/stream?${params}`);
+        this.logStream = source;
+
+        source.addEventListener('pod', (e) => {
+            const event = JSON.parse(e.data);
+            appendLine(`==> pod ${event.pod}, container ${event.container} <==`);
+        });
+        source.addEventListener('line', (e) => {
+            appendLine(JSON.parse(e.data).line);
+        });
+        source.addEventListener('waiting', (e) => {
+            const event = JSON.parse(e.data);
+            appendLine(`Waiting for ${event.pod} to start...`);
+        });
+        source.addEventListener('end', (e) => {
+            appendLine(`--- ${JSON.parse(e.data).message} ---`);
+            this.stopLogStream();
+        });
+        source.addEventListener('error', (e) => {
+            // Named error events carry data, connection errors do not
+            if (e.data) {
+                appendLine(`Error: ${JSON.parse(e.data).message}`);
+                return;
+            }
+            // Reconnecting would replay the logs from the start
+            if (this.logStream === source) {
+                appendLine('--- Log stream disconnected ---');
+                this.stopLogStream();
+            }
+        });
+    }
+
+    stopLogStream() {
+        if (this.logStream) {
+            this.logStream.close();
+            this.logStream = null;
+        }
+    }
+
+    async rerunJob(namespace, name) {
+        const job = this.jobs.get(`${namespace}/${name}`);
+        const previousCommand = (job && job.source && job.source.command) || '';
+        const command = prompt('Command for the new run (leave unchanged to reuse it):', previousCommand);
+        if (command === null) {
+            return;
+        }
+
+        // Only override the command when it was changed, the recorded command
+        // of exec-form jobs is not a shell command
+        const body = {};
+        if (command.trim() && command !== previousCommand) {
+            body.command = command;
+        }
+
+        try {
+            const response = await this.apiFetch(`/api/v1/jobs/${namespace}/${name}/rerun`, {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json'
+                },
+                body: JSON.stringify(body)
+            });
+
+            if (response.ok) {
+                const result = await response.json();
+                this.showAlert(`Started ${result.job.name}`, 'success');
+                this.addJobCard(result.job, true);
+            } else {
+                const error = await response.json();
+                this.showAlert(`Failed to rerun job: ${error.error}`, 'danger');
+            }
+        } catch (error) {
+            console.error('Failed to rerun job:', error);
+            this.showAlert('Failed to rerun job', 'danger');
+        }
+    }
+
+    // showJobDetails shows the diagnosis and the Kubernetes Events of a job
+    async showJobDetails(namespace, name) {
+        const modal = new bootstrap.Modal(document.getElementById('detailsModal'));
+        const diagnosisContent = document.getElementById('diagnosisContent');
+        const eventsContent = document.getElementById('eventsContent');
+        diagnosisContent.textContent = 'Loading...';
+        eventsContent.textContent = 'Loading...';
+        modal.show();
+
+        try {
+            const [jobResponse, eventsResponse] = await Promise.all([
+                this.apiFetch(`/api/v1/jobs/${namespace}/${name}?diagnose=true`),
+                this.apiFetch(`/api/v1/jobs/${namespace}/${name}/events`)
+            ]);
+
+            if (jobResponse.ok) {
+                const job = await jobResponse.json();
+                const problems = (job.diagnosis && job.diagnosis.problems) || [];
+                diagnosisContent.innerHTML = problems.length === 0
+                    ? '<p class="text-muted small">No problems found</p>'
+                    : problems.map(problem => this.renderProblem(problem)).join('');
+            } else {
+                const error = await jobResponse.json();
+                diagnosisContent.textContent = `Failed to load job: ${error.error}`;
+            }
+
+            if (eventsResponse.ok) {
+                const events = await eventsResponse.json();
+                eventsContent.innerHTML = events.length === 0
+                    ? '<p class="text-muted small">No events found</p>'
+                    : `<table class="table table-sm small">
+                        <thead><tr><th>Last seen</th><th>Type</th><th>Object</th><th>Reason</th><th>Message</th></tr></thead>
+                        <tbody>${events.map(event => `
+                            <tr class="${event.type === 'Warning' ? 'table-warning' : ''}">
+                                <td>${new Date(event.lastSeen).toLocaleString()}</td>
+                                <td>${this.escapeHtml(event.type)}</td>
+                                <td>${this.escapeHtml(`${event.kind}/${event.name}`)}</td>
+                                <td>${this.escapeHtml(event.reason)}${event.count > 1 ? ` (x${event.count})` : ''}</td>
+                                <td>${this.escapeHtml(event.message)}</td>
+                            </tr>`).join('')}
+                        </tbody>
+                    </table>`;
+            } else {
+                const error = await eventsResponse.json();
+                eventsContent.textContent = `Failed to load events: ${error.error}`;
+            }
+        } catch (error) {
+            console.error('Failed to load job details:', error);
+            diagnosisContent.textContent = 'Error loading job details';
+            eventsContent.textContent = '';
+        }
+    }
+
+    renderProblem(problem) {
+        const target = [problem.pod, problem.container].filter(Boolean).join(' / ');
+        const exitCode = problem.exitCode !== undefined ? ` (exit code ${problem.exitCode})` : '';
+        const logs = (problem.lastLogLines || []).join('\n');
+        return `
+            <div class="alert alert-danger py-2 small">
+                <strong>${this.escapeHtml(problem.reason)}</strong>${exitCode}
+                ${target ? `<span class="text-muted">${this.escapeHtml(target)}</span>` : ''}
+                ${problem.message ? `<div>${this.escapeHtml(problem.message)}</div>` : ''}
+                ${logs ? `<pre class="log-container mt-2 mb-0">${this.escapeHtml(logs)}</pre>` : ''}
+            </div>
+        `;
+    }
+
+    // showJobHistory lists the runs linked to a job through reruns, oldest first
+    showJobHistory(namespace, name) {
+        const key = jobName => `${namespace}/${jobName}`;
+
+        // Walk back to the first run
+        let root = name;
+        const visited = new Set([root]);
+        while (true) {
+            const job = this.jobs.get(key(root));
+            const parent = job && job.source && job.source.rerunOf;
+            if (!parent || visited.has(parent)) {
+                break;
+            }
+            visited.add(parent);
+            root = parent;
+        }
+
+        // Collect every rerun descending from the first run
+        const runs = [];
+        const queue = [root];
+        const seen = new Set();
+        while (queue.length > 0) {
+            const current = queue.shift();
+            if (seen.has(current)) {
+                continue;
+            }
+            seen.add(current);
+            runs.push(current);
+            this.jobs.forEach(job => {
+                if (job.namespace === namespace && job.source && job.source.rerunOf === current) {
+                    queue.push(job.name);
+                }
+            });
+        }
+
+        const rows = runs.map(runName => {
+            const job = this.jobs.get(key(runName));
+            if (!job) {
+                return `<li class="list-group-item text-muted">${this.escapeHtml(runName)} (deleted)</li>`;
+            }
+            const status = job.status;
+            const current = runName === name ? ' active' : '';
+            return `<li class="list-group-item d-flex justify-content-between${current}">
+                <span>${this.escapeHtml(runName)}<br><small>${new Date(job.createdAt).toLocaleString()}</small></span>
+                <span class="badge ${this.getStatusClass(status)} align-self-center">${status}</span>
+            </li>`;
+        });
+
+        document.getElementById('historyContent').innerHTML = `<ul class="list-group">${rows.join('')}</ul>`;
+        new bootstrap.Modal(document.getElementById('historyModal')).show();
+    }
+
     async deleteJob(namespace, name) {
         if (!confirm(`Are you sure you want to delete job "${name}"?`)) {
             return;
         }
 
         try {
-            const response = await fetch(`/api/jobs/${namespace}/${name}`, {
+            const response = await this.apiFetch(`/api/v1/jobs/${namespace}/${name}`, {
                 method: 'DELETE'
             });
 
             if (response.ok) {
                 this.showAlert('Job deleted successfully', 'success');
-                const jobCard = document.getElementById(`job-${name}`);
-                if (jobCard) {
-                    jobCard.remove();
-                }
+                this.removeJobCard(namespace, name);
             } else {
                 const error = await response.json();
                 this.showAlert(`Failed to delete job: ${error.error}`, 'danger');
@@ -436,6 +1010,12 @@ class SpawnrApp {
     clearForm() {
         document.getElementById('jobName').value = '';
         document.getElementById('command').value = '';
+        document.getElementById('imageOverride').value = '';
+        document.getElementById('jobReason').value = '';
+        document.getElementById('envOverrides').value = '';
+        ['cpuRequest', 'cpuLimit', 'memoryRequest', 'memoryLimit'].forEach(id => {
+            document.getElementById(id).value = '';
+        });
         this.updateCreateJobButton();
     }
 
@@ -469,6 +1049,8 @@ class SpawnrApp {
 
     async refreshJobs() {
         try {
+            // Check the permissions again, they may have been granted meanwhile
+            this.capabilities.clear();
             await this.loadAllJobs();
             this.showAlert('Jobs refreshed', 'success');
         } catch (error) {
@@ -477,32 +1059,44 @@ class SpawnrApp {
         }
     }
 
+    // Show only the add cluster fields used by the selected cluster type
+    updateClusterTypeFields() {
+        const type = document.getElementById('clusterType').value;
+        document.querySelectorAll('#addClusterForm [data-cluster-types]').forEach(element => {
+            element.style.display = element.dataset.clusterTypes.split(' ').includes(type) ? '' : 'none';
+        });
+    }
+
     async addCluster() {
-        const clusterName = document.getElementById('clusterName').value;
-        const friendlyName = document.getElementById('friendlyName').value;
-        const roleArn = document.getElementById('roleArn').value;
-        const endpoint = document.getElementById('endpoint').value;
-        const certificateAuthority = document.getElementById('certificateAuthority').value.trim();
+        const value = id => document.getElementById(id).value.trim();
+        const type = value('clusterType');
+        const requestBody = {
+            type: type,
+            clusterName: value('clusterName'),
+            friendlyName: value('friendlyName')
+        };
+
+        // Fields sent per cluster type, the server validates them
+        const fields = {
+            'eks': { roleArn: 'roleArn', endpoint: 'endpoint', certificateAuthority: 'certificateAuthority' },
+            'kubeconfig': { kubeconfig: 'kubeconfig', context: 'kubeconfigContext' },
+            'token': { endpoint: 'endpoint', token: 'clusterToken', certificateAuthority: 'certificateAuthority' },
+            'client-certificate': { endpoint: 'endpoint', clientCertificate: 'clientCertificate', clientKey: 'clientKey', certificateAuthority: 'certificateAuthority' },
+            'service-account': { endpoint: 'endpoint', token: 'clusterToken', certificateAuthority: 'certificateAuthority' }
+        };
+        Object.entries(fields[type] || {}).forEach(([field, id]) => {
+            if (value(id)) {
+                requestBody[field] = value(id);
+            }
+        });
 
-        if (!clusterName || !friendlyName || !roleArn || !endpoint) {
+        if (!requestBody.clusterName || !requestBody.friendlyName) {
             this.showAlert('Please fill in all required fields', 'danger');
             return;
         }
 
         try {
-            const requestBody = {
-                clusterName: clusterName,
-                friendlyName: friendlyName,
-                roleArn: roleArn,
-                endpoint: endpoint
-            };
-
-            // Only include certificate if provided
-            if (certificateAuthority) {
-                requestBody.certificateAuthority = certificateAuthority;
-            }
-
-            const response = await fetch('/api/clusters', {
+            const response = await fetch('/api/v1/clusters', {
                 method: 'POST',
                 headers: {
                     'Content-Type': 'application/json'
@@ -517,6 +1111,7 @@ class SpawnrApp {
                 modal.hide();
                 // Clear the form
                 document.getElementById('addClusterForm').reset();
+                this.updateClusterTypeFields();
                 // Reload clusters
                 await this.loadClusters();
             } else {
@@ -534,7 +1129,7 @@ class SpawnrApp {
         container.innerHTML = '<div class="col-12 text-center"><i class="fas fa-spinner fa-spin"></i> Loading clusters...</div>';
 
         try {
-            const response = await fetch('/api/clusters');
+            const response = await fetch('/api/v1/clusters');
             if (response.ok) {
                 const clusters = await response.json();
                 container.innerHTML = '';
@@ -560,6 +1155,8 @@ class SpawnrApp {
         const container = document.getElementById('clustersContainer');
         const clusterName = cluster.originalName || cluster.name;
         const isLocal = clusterName === 'local';
+        // Kubeconfig contexts are managed in the kubeconfig, not in spawnr
+        const isContext = cluster.type === 'context';
 
         const card = document.createElement('div');
         card.className = 'col-md-6 col-lg-4';
@@ -571,19 +1168,16 @@ class SpawnrApp {
                     </h5>
                     <p class="card-text">
                         <small class="text-muted">
-                            <i class="fas fa-map-marker-alt"></i> Region: ${cluster.region || 'N/A'}<br>
-                            ${isLocal ? '<i class="fas fa-laptop"></i> Local Cluster' : `<i class="fas fa-link"></i> ${cluster.originalName}`}
+                            ${cluster.type && cluster.type !== 'eks' ? `<i class="fas fa-key"></i> Type: ${cluster.type}<br>` : `<i class="fas fa-map-marker-alt"></i> Region: ${cluster.region || 'N/A'}<br>`}
+                            ${isLocal ? '<i class="fas fa-laptop"></i> Local Cluster' : isContext ? `<i class="fas fa-file-code"></i> Kubeconfig context` : `<i class="fas fa-link"></i> ${cluster.originalName}`}
                         </small>
                     </p>
-                    <div class="status-display mb-3" id="status-${clusterName}">
-                        <span class="status-indicator status-unknown"></span>
-                        <small>Status: Not tested</small>
-                    </div>
+                    <div class="status-display mb-3" id="status-${clusterName}"></div>
                     <div class="btn-group w-100" role="group">
                         <button class="btn btn-sm btn-outline-primary test-connectivity-btn" data-cluster="${clusterName}">
                             <i class="fas fa-plug"></i> Test Connection
                         </button>
-                        ${!isLocal ? `<button class="btn btn-sm btn-outline-danger delete-cluster-btn" data-cluster="${clusterName}">
+                        ${!isLocal && !isContext ? `<button class="btn btn-sm btn-outline-danger delete-cluster-btn" data-cluster="${clusterName}">
                             <i class="fas fa-trash"></i>
                         </button>` : ''}
                     </div>
@@ -592,6 +1186,13 @@ class SpawnrApp {
         `;
 
         container.appendChild(card);
+        this.renderClusterHealth(clusterName, {
+            status: cluster.status,
+            version: cluster.version,
+            latencyMs: cluster.latencyMs,
+            missingPermissions: cluster.missingPermissions,
+            message: cluster.statusMessage
+        });
 
         // Add event listener for test connectivity button
         const testBtn = card.querySelector('.test-connectivity-btn');
@@ -600,7 +1201,7 @@ class SpawnrApp {
         });
 
         // Add event listener for delete button (if not local)
-        if (!isLocal) {
+        if (!isLocal && !isContext) {
             const deleteBtn = card.querySelector('.delete-cluster-btn');
             deleteBtn.addEventListener('click', () => {
                 this.deleteCluster(clusterName, cluster.name);
@@ -616,47 +1217,54 @@ class SpawnrApp {
         statusDiv.innerHTML = '<span class="status-indicator status-testing"></span><small>Testing connection...</small>';
 
         try {
-            // Try to switch to the cluster and get namespaces
-            const switchResponse = await fetch('/api/clusters/switch', {
-                method: 'POST',
-                headers: {
-                    'Content-Type': 'application/json'
-                },
-                body: JSON.stringify({
-                    clusterName: clusterName
-                })
-            });
-
-            if (!switchResponse.ok) {
-                throw new Error('Failed to switch to cluster');
-            }
-
-            // Try to get namespaces
-            const namespacesResponse = await fetch('/api/namespaces');
-            
-            if (namespacesResponse.ok) {
-                const namespaces = await namespacesResponse.json();
-                statusDiv.innerHTML = `<span class="status-indicator status-success"></span><small>✓ Connected (${namespaces.length} namespaces)</small>`;
-                this.clusterStatuses.set(clusterName, 'success');
-            } else {
-                const error = await namespacesResponse.json();
-                statusDiv.innerHTML = `<span class="status-indicator status-error"></span><small>✗ Error: ${error.error || 'Unknown error'}</small>`;
-                this.clusterStatuses.set(clusterName, 'error');
+            // Probe the cluster again instead of reusing the cached result
+            const response = await fetch(`/api/v1/clusters/${encodeURIComponent(clusterName)}/health?refresh=true`);
+            if (!response.ok) {
+                const error = await response.json();
+                throw new Error(error.error || 'Unknown error');
             }
+            this.renderClusterHealth(clusterName, await response.json());
         } catch (error) {
             console.error('Connection test failed:', error);
-            statusDiv.innerHTML = `<span class="status-indicator status-error"></span><small>✗ Connection failed: ${error.message}</small>`;
+            statusDiv.innerHTML = `<span class="status-indicator status-error"></span><small>✗ Connection failed: ${this.escapeHtml(error.message)}</small>`;
             this.clusterStatuses.set(clusterName, 'error');
         }
     }
 
+    renderClusterHealth(clusterName, health) {
+        const statusDiv = document.getElementById(`status-${clusterName}`);
+        if (!statusDiv) return;
+
+        const indicators = {
+            ACTIVE: 'status-success',
+            DEGRADED: 'status-warning',
+            UNAUTHORIZED: 'status-error',
+            UNREACHABLE: 'status-error'
+        };
+        const status = health.status || 'UNKNOWN';
+        const indicator = indicators[status] || 'status-unknown';
+
+        const details = [];
+        if (health.version) details.push(this.escapeHtml(health.version));
+        if (health.latencyMs) details.push(`${health.latencyMs} ms`);
+
+        let html = `<span class="status-indicator ${indicator}"></span><small>Status: ${this.escapeHtml(status)}${details.length ? ` (${details.join(', ')})` : ''}</small>`;
+        if (health.missingPermissions && health.missingPermissions.length > 0) {
+            html += `<br><small class="text-warning"><i class="fas fa-lock"></i> Missing permissions: ${health.missingPermissions.map(p => this.escapeHtml(p)).join(', ')}</small>`;
+        } else if (health.message && status !== 'ACTIVE') {
+            html += `<br><small class="text-muted">${this.escapeHtml(health.message)}</small>`;
+        }
+        statusDiv.innerHTML = html;
+        this.clusterStatuses.set(clusterName, status === 'ACTIVE' ? 'success' : status === 'UNKNOWN' ? 'unknown' : 'error');
+    }
+
     async deleteCluster(clusterName, friendlyName) {
         if (!confirm(`Are you sure you want to delete cluster "${friendlyName}"?`)) {
             return;
         }
 
         try {
-            const response = await fetch(`/api/clusters/${encodeURIComponent(clusterName)}`, {
+            const response = await fetch(`/api/v1/clusters/${encodeURIComponent(clusterName)}`, {
                 method: 'DELETE'
             });
 